@@ -0,0 +1,155 @@
+package gobjdump
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const BootROMSize = 0x100
+
+type AnalyzeOptions struct {
+	/* Treat the image as a DMG boot ROM; implied by a 256 byte image */
+	BootROM bool
+}
+
+type ROMAnalysis struct {
+	BootROM bool
+	/* RST and interrupt table at 0x0000-0x0067, cartridges only */
+	RSTTable []*GBInstruction
+	/* The entry point at 0x0100 up to and including its jump, cartridges only */
+	Trampoline []*GBInstruction
+	/* The Nintendo logo at 0x0104-0x0133 as db directives, cartridges only */
+	Logo []*GBInstruction
+	/* Whether Logo matches NintendoLogo; the boot ROM locks up if not */
+	LogoOK bool
+	/* Where control continues: the cartridge code start, or 0x0100 for a boot ROM */
+	CodeStart uint32
+	/* The code at CodeStart, or the whole boot ROM */
+	Code []*GBInstruction
+}
+
+/*
+ * Splits rom into its well-known regions and disassembles each of them. A
+ * cartridge is followed from its entry point at 0x0100 to its code start; a
+ * boot ROM is disassembled whole, with the write to 0xff50 that unmaps it and
+ * the handoff to the cartridge at 0x0100 annotated.
+ */
+func AnalyzeROM(rom []uint8, opts AnalyzeOptions) (*ROMAnalysis, error) {
+	if opts.BootROM || len(rom) == BootROMSize {
+		return analyzeBootROM(rom)
+	}
+	return AnalyzePreamble(bytes.NewReader(rom))
+}
+
+func analyzeBootROM(rom []uint8) (*ROMAnalysis, error) {
+	analysis := &ROMAnalysis{BootROM: true, CodeStart: 0x0100}
+	code, err := Disassemble(bytes.NewReader(rom), 0x0000, BootROMSize)
+	analysis.Code = code
+	for _, gbInstruction := range code {
+		annotateBootROM(gbInstruction)
+	}
+	return analysis, err
+}
+
+func annotateBootROM(i *GBInstruction) {
+	if i.Err != nil {
+		return
+	}
+	b := i.Instruction
+	switch {
+	case b[0] == 0xe0 && b[1] == 0x50, b[0] == 0xea && b[1] == 0x50 && b[2] == 0xff:
+		i.Comments = append(i.Comments, "unmap boot ROM")
+	case b[0] == 0xc3 && b[1] == 0x00 && b[2] == 0x01:
+		i.Comments = append(i.Comments, "hand off to cartridge")
+		return
+	}
+	if i.Addr+uint32(len(b)) == BootROMSize {
+		i.Comments = append(i.Comments, "falls through to cartridge at 0x0100")
+	}
+}
+
+/* Names of the rst vectors and interrupt handlers in the table at 0x0000-0x0067 */
+var VectorLabels = map[uint32]string{
+	0x0000: "RST_00",
+	0x0008: "RST_08",
+	0x0010: "RST_10",
+	0x0018: "RST_18",
+	0x0020: "RST_20",
+	0x0028: "RST_28",
+	0x0030: "RST_30",
+	0x0038: "RST_38",
+	0x0040: "VBlank",
+	0x0048: "STAT",
+	0x0050: "Timer",
+	0x0058: "Serial",
+	0x0060: "Joypad",
+}
+
+/*
+ * Disassembles the RST and interrupt table of a cartridge, then follows its
+ * entry point at 0x0100 to the code start and disassembles up to 0x8000. The
+ * Nintendo logo is never decoded; it is listed as data, with a warning on its
+ * first line if it is not the logo the boot ROM expects. On error the analysis
+ * holds everything decoded before it.
+ */
+func AnalyzePreamble(reader *bytes.Reader) (*ROMAnalysis, error) {
+	analysis := &ROMAnalysis{}
+	reader.Seek(0x0000, 0)
+
+	/* 0x0000 - 0x0067 contains the RST and Interrupt tables */
+	var err error
+	analysis.RSTTable, err = Disassemble(reader, 0x0000, 0x0068)
+	if err != nil {
+		return analysis, err
+	}
+
+	/*
+	 * Code entry point is at 0x0100-0x0103
+	 * It is almost always nop followed by jp, sometimes a bare jr
+	 */
+	var addr uint32 = 0x0100
+	reader.Seek(int64(addr), 0)
+	var gbInstruction *GBInstruction
+	for gbInstruction, addr = DecodeInstruction(reader, addr); gbInstruction != nil; gbInstruction, addr = DecodeInstruction(reader, addr) {
+		analysis.Trampoline = append(analysis.Trampoline, gbInstruction)
+		if len(gbInstruction.Instruction) == 0 {
+			return analysis, gbInstruction.Err
+		}
+		if gbInstruction.Instruction[0] != 0x00 {
+			break
+		}
+	}
+	if gbInstruction == nil {
+		return analysis, fmt.Errorf("entry point runs off the end of the ROM")
+	}
+
+	if gbInstruction.Err != nil {
+		return analysis, gbInstruction.Err
+	}
+	switch gbInstruction.Instruction[0] {
+	case 0xc3, 0x18: /* jp nn, jr e */
+		target, _ := branchTarget(gbInstruction)
+		analysis.CodeStart = target
+	default:
+		/* A conditional or computed jump, or code that runs on past the header */
+		text := strings.TrimSpace(gbInstruction.Op() + " " + strings.Join(gbInstruction.Operands(), ", "))
+		return analysis, fmt.Errorf("cannot follow entry point: %q at 0x%04x is not an unconditional jp or jr",
+			text, gbInstruction.Addr)
+	}
+
+	var logo [LogoEnd - LogoStart]uint8
+	if _, err := reader.ReadAt(logo[:], LogoStart); err != nil {
+		return analysis, fmt.Errorf("reading Nintendo logo: %w", err)
+	}
+	analysis.Logo = dataInstructions(logo[:], LogoStart, dataBytesPerLine)
+	analysis.LogoOK = logo == NintendoLogo
+	if !analysis.LogoOK {
+		analysis.Logo[0].Comments = append(analysis.Logo[0].Comments,
+			"warning: not the Nintendo logo, the boot ROM will lock up")
+	}
+
+	reader.Seek(int64(analysis.CodeStart), 0)
+	analysis.Code, err = Disassemble(reader, analysis.CodeStart, 0x8000)
+	return analysis, err
+}
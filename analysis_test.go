@@ -0,0 +1,196 @@
+package gobjdump
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeBootROM(t *testing.T) {
+	rom := make([]uint8, BootROMSize)
+	/* ld a, 0x01; ldh [0x50], a; ...; jp 0x0100 at the end */
+	copy(rom[0x00:], []uint8{0x3e, 0x01, 0xe0, 0x50})
+	copy(rom[0x10:], []uint8{0xea, 0x50, 0xff, 0xc3, 0x00, 0x01})
+	copy(rom[0xfc:], []uint8{0x3e, 0x01, 0xe0, 0x50})
+	tests := []struct {
+		name string
+		rom  []uint8
+		opts AnalyzeOptions
+	}{
+		{"by size", rom, AnalyzeOptions{}},
+		{"by option", append(slices.Clone(rom), make([]uint8, 0x100)...), AnalyzeOptions{BootROM: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis, err := AnalyzeROM(tt.rom, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !analysis.BootROM || analysis.CodeStart != 0x0100 {
+				t.Fatalf("BootROM %v CodeStart 0x%04x, want a boot ROM handing off at 0x0100",
+					analysis.BootROM, analysis.CodeStart)
+			}
+			if analysis.RSTTable != nil || analysis.Trampoline != nil || analysis.Logo != nil {
+				t.Errorf("boot ROM analysis has cartridge sections")
+			}
+			want := map[uint32][]string{
+				0x0002: {"unmap boot ROM"},
+				0x0010: {"unmap boot ROM"},
+				0x0013: {"hand off to cartridge"},
+				0x00fe: {"unmap boot ROM", "falls through to cartridge at 0x0100"},
+			}
+			for _, i := range analysis.Code {
+				if !slices.Equal(i.Comments, want[i.Addr]) {
+					t.Errorf("comments at 0x%04x = %q, want %q", i.Addr, i.Comments, want[i.Addr])
+				}
+			}
+			if last := analysis.Code[len(analysis.Code)-1]; last.Addr != 0x00fe {
+				t.Errorf("last instruction at 0x%04x, want 0x00fe", last.Addr)
+			}
+		})
+	}
+}
+
+func TestAnalyzePreambleCodeStart(t *testing.T) {
+	rom := make([]uint8, 0x8000)
+	copy(rom[0x0104:], NintendoLogo[:])
+	copy(rom[0x0100:], []uint8{0x00, 0xc3, 0x50, 0x02})
+	copy(rom[0x0250:], []uint8{0x31, 0xfe, 0xff})
+	analysis, err := AnalyzeROM(rom, AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.BootROM {
+		t.Error("analyzed as a boot ROM")
+	}
+	if analysis.CodeStart != 0x0250 {
+		t.Errorf("CodeStart = 0x%04x, want 0x0250 from the jp", analysis.CodeStart)
+	}
+	if len(analysis.Trampoline) != 2 || analysis.Trampoline[1].Op() != "jp" {
+		t.Errorf("trampoline %v, want nop then jp", analysis.Trampoline)
+	}
+	if len(analysis.RSTTable) == 0 || analysis.RSTTable[len(analysis.RSTTable)-1].Addr >= 0x0068 {
+		t.Errorf("RST table does not end before 0x0068")
+	}
+	if got := analysis.Code[0].ToStr(); got != "0x0250: 31feff       ld     sp, 0xfffe" {
+		t.Errorf("code starts with %q", got)
+	}
+	if last := analysis.Code[len(analysis.Code)-1]; last.Addr != 0x7fff {
+		t.Errorf("code ends at 0x%04x, want 0x7fff", last.Addr)
+	}
+}
+
+func TestAnalyzePreambleEntryPoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		entry     []uint8
+		romSize   int
+		wantStart uint32
+		wantErr   string
+	}{
+		{"nop jp", []uint8{0x00, 0xc3, 0x50, 0x01}, 0x8000, 0x0150, ""},
+		{"bare jp", []uint8{0xc3, 0x50, 0x01}, 0x8000, 0x0150, ""},
+		{"jr", []uint8{0x18, 0x4e}, 0x8000, 0x0150, ""},
+		{"nop jr", []uint8{0x00, 0x18, 0x4d}, 0x8000, 0x0150, ""},
+		{"conditional jp", []uint8{0x00, 0xc2, 0x50, 0x01}, 0x8000, 0,
+			`cannot follow entry point: "jp NZ, 0x0150" at 0x0101 is not an unconditional jp or jr`},
+		{"jp hl", []uint8{0xe9}, 0x8000, 0, `"jp [hl]" at 0x0100`},
+		{"runs off the end", []uint8{0x00, 0x00}, 0x0102, 0, "entry point runs off the end of the ROM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rom := make([]uint8, tt.romSize)
+			copy(rom[0x0100:], tt.entry)
+			analysis, err := AnalyzeROM(rom, AnalyzeOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				if len(analysis.RSTTable) == 0 {
+					t.Error("the RST table decoded before the error is missing")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if analysis.CodeStart != tt.wantStart {
+				t.Errorf("CodeStart = 0x%04x, want 0x%04x", analysis.CodeStart, tt.wantStart)
+			}
+		})
+	}
+}
+
+/* The interrupt vectors are labelled, and calls and rsts to them print the label */
+func TestPreambleVectorLabels(t *testing.T) {
+	rom := make([]uint8, 0x0160)
+	copy(rom[0x0100:], []uint8{0x00, 0xc3, 0x50, 0x01})
+	rom[0x0040] = 0xd9
+	rom[0x0048] = 0xd9
+	copy(rom[0x0150:], []uint8{0xcd, 0x40, 0x00, 0xc7, 0xc9})
+	var buf bytes.Buffer
+	if err := GBROMPreambleTo(&buf, bytes.NewReader(rom), PreambleOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"vblank", "VBlank:\n0x0040: d9           reti\n"},
+		{"stat", "STAT:\n0x0048: d9           reti\n"},
+		{"call", "0x0150: cd4000       call   VBlank\n"},
+		{"rst", "0x0153: c7           rst    RST_00\n"},
+	}
+	for _, tt := range tests {
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("%s: output missing %q:\n%s", tt.name, tt.want, buf.String())
+		}
+	}
+}
+
+/* The logo is listed as db lines, never decoded, with a warning only when it is wrong */
+func TestAnalyzePreambleLogo(t *testing.T) {
+	corrupt := NintendoLogo
+	corrupt[len(corrupt)-1] ^= 0xff
+	tests := []struct {
+		name     string
+		logo     [LogoEnd - LogoStart]uint8
+		ok       bool
+		comments []string
+	}{
+		{"nintendo logo", NintendoLogo, true, nil},
+		{"corrupted", corrupt, false, []string{"warning: not the Nintendo logo, the boot ROM will lock up"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rom := make([]uint8, 0x0160)
+			copy(rom[0x0100:], []uint8{0x00, 0xc3, 0x50, 0x01})
+			copy(rom[LogoStart:], tt.logo[:])
+			analysis, err := AnalyzeROM(rom, AnalyzeOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if analysis.LogoOK != tt.ok {
+				t.Errorf("LogoOK = %v, want %v", analysis.LogoOK, tt.ok)
+			}
+			var data []uint8
+			for n, i := range analysis.Logo {
+				if i.Op() != "db" {
+					t.Errorf("logo line at 0x%04x is %q, want db", i.Addr, i.Op())
+				}
+				want := []string(nil)
+				if n == 0 {
+					want = tt.comments
+				}
+				if !slices.Equal(i.Comments, want) {
+					t.Errorf("comments at 0x%04x = %q, want %q", i.Addr, i.Comments, want)
+				}
+				data = append(data, i.Instruction...)
+			}
+			if analysis.Logo[0].Addr != LogoStart || !bytes.Equal(data, tt.logo[:]) {
+				t.Errorf("logo from 0x%04x is % x, want % x", analysis.Logo[0].Addr, data, tt.logo)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package gobjdump
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ * One encoding the assembler can emit. operands holds the decoded tokens with
+ * the immediate, if any, at immIndex; the immediate is matched by stripping
+ * immPrefix and immSuffix and parsing what is left in immBase.
+ */
+type asmTemplate struct {
+	encoding  []uint8
+	operands  []string
+	immIndex  int
+	immPrefix string
+	immSuffix string
+	/* 16 when the digits continue a hex literal in immPrefix, as in "[0xffNN]" */
+	immBase   int
+	immSigned bool
+	/* Offset of the immediate within encoding and its width in bytes */
+	immOffset, immWidth int
+}
+
+/*
+ * Built like the length tables: every opcode is decoded twice, with its
+ * immediate bytes all clear and all set, and the operand that differs between
+ * the two decodes is the immediate.
+ */
+var asmTemplates = buildAsmTemplates()
+
+func buildAsmTemplates() map[string][]asmTemplate {
+	templates := make(map[string][]asmTemplate)
+	add := func(opcode []uint8) {
+		zero, _ := DecodeInstruction(bytes.NewReader(append(opcode, 0x00, 0x00)), 0)
+		ones, _ := DecodeInstruction(bytes.NewReader(append(opcode, 0xff, 0xff)), 0)
+		if zero.Err != nil || ones.Err != nil {
+			return
+		}
+		t := asmTemplate{
+			encoding:  zero.Instruction,
+			operands:  zero.Mnemonic[1:],
+			immIndex:  -1,
+			immOffset: len(opcode),
+			immWidth:  len(zero.Instruction) - len(opcode),
+		}
+		for n := range t.operands {
+			if t.operands[n] != ones.Mnemonic[n+1] {
+				t.immIndex = n
+				t.immPrefix, t.immSuffix, t.immBase = immAffixes(t.operands[n], ones.Mnemonic[n+1])
+				t.immSigned = strings.HasPrefix(ones.Mnemonic[n+1][len(t.immPrefix):], "-")
+			}
+		}
+		op := zero.Mnemonic[0]
+		templates[op] = append(templates[op], t)
+	}
+	for op := 0; op < 256; op++ {
+		if op != 0xcb {
+			add([]uint8{uint8(op)})
+		}
+		add([]uint8{0xcb, uint8(op)})
+	}
+	return templates
+}
+
+/*
+ * Returns the text around the number in two renderings of an immediate and
+ * the base to parse it in. A leading "0x" is left to strconv so decimal is
+ * accepted too, unless the number is the tail of a longer hex literal.
+ */
+func immAffixes(a, b string) (string, string, int) {
+	p := 0
+	for p < len(a) && p < len(b) && a[p] == b[p] {
+		p++
+	}
+	s := 0
+	for s < len(a)-p && s < len(b)-p && a[len(a)-1-s] == b[len(b)-1-s] {
+		s++
+	}
+	prefix, suffix := a[:p], a[len(a)-s:]
+	if strings.HasSuffix(prefix, "0x") {
+		return strings.TrimSuffix(prefix, "0x"), suffix, 0
+	}
+	if strings.Contains(prefix, "0x") {
+		return prefix, suffix, 16
+	}
+	return prefix, suffix, 0
+}
+
+/* Parses an immediate operand against t, returning its value truncated to the template's width */
+func (t *asmTemplate) parseImm(token string) (uint16, bool) {
+	if !strings.HasPrefix(token, t.immPrefix) || !strings.HasSuffix(token, t.immSuffix) {
+		return 0, false
+	}
+	number := token[len(t.immPrefix) : len(token)-len(t.immSuffix)]
+	if number == "" || t.immBase == 16 && len(number) != 2*t.immWidth {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(number, t.immBase, 32)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case t.immSigned && (value < -0x80 || value > 0x7f):
+		return 0, false
+	case !t.immSigned && t.immWidth == 1 && (value < 0 || value > 0xff):
+		return 0, false
+	case !t.immSigned && (value < 0 || value > 0xffff):
+		return 0, false
+	}
+	return uint16(value), true
+}
+
+func (t *asmTemplate) assemble(operands []string) ([]uint8, bool) {
+	if len(operands) != len(t.operands) {
+		return nil, false
+	}
+	var imm uint16
+	for n, operand := range operands {
+		if n == t.immIndex {
+			var ok bool
+			if imm, ok = t.parseImm(operand); !ok {
+				return nil, false
+			}
+		} else if !strings.EqualFold(operand, t.operands[n]) {
+			return nil, false
+		}
+	}
+	encoding := append([]uint8(nil), t.encoding...)
+	if t.immIndex >= 0 {
+		encoding[t.immOffset] = uint8(imm)
+		if t.immWidth == 2 {
+			encoding[t.immOffset+1] = uint8(imm >> 8)
+		}
+	}
+	return encoding, true
+}
+
+/*
+ * Encodes one instruction given as the tokens DecodeInstruction produces,
+ * e.g. {"ld", "a", "0x12"}. Immediates may be written in hex or decimal;
+ * relative jumps take the signed offset, not the target. Register and
+ * condition names are matched case-insensitively. Where two encodings print
+ * alike, as ldh and ld [nn] do for [0xffNN], the shorter one is emitted.
+ * Likewise stop, which prints the same whatever its second byte, is always
+ * emitted as 10 00, so a stop with a nonzero second byte does not round-trip.
+ */
+func Assemble(mnemonic []string) ([]uint8, error) {
+	if len(mnemonic) == 0 {
+		return nil, fmt.Errorf("empty mnemonic")
+	}
+	templates, ok := asmTemplates[strings.ToLower(mnemonic[0])]
+	if !ok {
+		return nil, fmt.Errorf("unknown instruction %q", mnemonic[0])
+	}
+	for n := range templates {
+		if encoding, ok := templates[n].assemble(mnemonic[1:]); ok {
+			return encoding, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported operands for %s: %s", mnemonic[0], strings.Join(mnemonic[1:], ", "))
+}
@@ -0,0 +1,90 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAssembleRoundTrip(t *testing.T) {
+	for _, data := range [][]uint8{
+		{0x00},
+		{0x01, 0x34, 0x12},
+		{0x08, 0x00, 0xc0},
+		{0x10, 0x00},
+		{0x18, 0xfe},
+		{0x20, 0x05},
+		{0x22},
+		{0x3e, 0x0f},
+		{0x36, 0x80},
+		{0x78},
+		{0x7e},
+		{0x86},
+		{0xc3, 0x50, 0x01},
+		{0xc6, 0xff},
+		{0xcb, 0x37},
+		{0xcb, 0x7e},
+		{0xcb, 0xff},
+		{0xcd, 0x00, 0x40},
+		{0xd8},
+		{0xd9},
+		{0xdf},
+		{0xe0, 0x40},
+		{0xe2},
+		{0xe8, 0xfd},
+		{0xe9},
+		{0xea, 0x00, 0xc0},
+		{0xf0, 0x44},
+		{0xf8, 0x02},
+		{0xf9},
+		{0xfa, 0x00, 0xd0},
+	} {
+		i, _ := DecodeInstruction(bytes.NewReader(data), 0x0150)
+		if i.Err != nil {
+			t.Fatalf("% x: %v", data, i.Err)
+		}
+		got, err := Assemble(i.Mnemonic)
+		if err != nil {
+			t.Errorf("Assemble(%q) from % x: %v", i.Mnemonic, data, err)
+			continue
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("Assemble(%q) = % x, want % x", i.Mnemonic, got, data)
+		}
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		mnemonic []string
+		want     []uint8
+	}{
+		{[]string{"LD", "A", "B"}, []uint8{0x78}},
+		{[]string{"ld", "a", "18"}, []uint8{0x3e, 0x12}},
+		{[]string{"jr", "-2"}, []uint8{0x18, 0xfe}},
+		{[]string{"ld", "[0xff40]", "a"}, []uint8{0xe0, 0x40}},
+		{[]string{"ld", "[0xc000]", "a"}, []uint8{0xea, 0x00, 0xc0}},
+		/* stop is always emitted as 10 00 */
+		{[]string{"stop"}, []uint8{0x10, 0x00}},
+	}
+	for _, tt := range tests {
+		got, err := Assemble(tt.mnemonic)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("Assemble(%q) = % x, %v; want % x", tt.mnemonic, got, err, tt.want)
+		}
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	for _, mnemonic := range [][]string{
+		nil,
+		{"frobnicate"},
+		{"ld", "a", "0x100"},
+		{"jr", "200"},
+		{"ld", "a"},
+		{"bit", "8", "a"},
+	} {
+		if got, err := Assemble(mnemonic); err == nil {
+			t.Errorf("Assemble(%q) = % x, want an error", mnemonic, got)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package gobjdump
+
+import (
+	"strconv"
+	"strings"
+)
+
+/* An SM83 mnemonic */
+type Opcode uint8
+
+const (
+	OpInvalid Opcode = iota
+	OpADC
+	OpADD
+	OpAND
+	OpBIT
+	OpCALL
+	OpCCF
+	OpCP
+	OpCPL
+	OpDAA
+	OpDEC
+	OpDI
+	OpEI
+	OpHALT
+	OpINC
+	OpJP
+	OpJR
+	OpLD
+	OpLDD
+	OpLDI
+	OpNOP
+	OpOR
+	OpPOP
+	OpPUSH
+	OpRES
+	OpRET
+	OpRETI
+	OpRL
+	OpRLA
+	OpRLC
+	OpRLCA
+	OpRR
+	OpRRA
+	OpRRC
+	OpRRCA
+	OpRST
+	OpSBC
+	OpSCF
+	OpSET
+	OpSLA
+	OpSRA
+	OpSRL
+	OpSTOP
+	OpSUB
+	OpSWAP
+	OpXOR
+)
+
+var opcodeNames = [...]string{
+	OpInvalid: "invalid",
+	OpADC:     "adc",
+	OpADD:     "add",
+	OpAND:     "and",
+	OpBIT:     "bit",
+	OpCALL:    "call",
+	OpCCF:     "ccf",
+	OpCP:      "cp",
+	OpCPL:     "cpl",
+	OpDAA:     "daa",
+	OpDEC:     "dec",
+	OpDI:      "di",
+	OpEI:      "ei",
+	OpHALT:    "halt",
+	OpINC:     "inc",
+	OpJP:      "jp",
+	OpJR:      "jr",
+	OpLD:      "ld",
+	OpLDD:     "ldd",
+	OpLDI:     "ldi",
+	OpNOP:     "nop",
+	OpOR:      "or",
+	OpPOP:     "pop",
+	OpPUSH:    "push",
+	OpRES:     "res",
+	OpRET:     "ret",
+	OpRETI:    "reti",
+	OpRL:      "rl",
+	OpRLA:     "rla",
+	OpRLC:     "rlc",
+	OpRLCA:    "rlca",
+	OpRR:      "rr",
+	OpRRA:     "rra",
+	OpRRC:     "rrc",
+	OpRRCA:    "rrca",
+	OpRST:     "rst",
+	OpSBC:     "sbc",
+	OpSCF:     "scf",
+	OpSET:     "set",
+	OpSLA:     "sla",
+	OpSRA:     "sra",
+	OpSRL:     "srl",
+	OpSTOP:    "stop",
+	OpSUB:     "sub",
+	OpSWAP:    "swap",
+	OpXOR:     "xor",
+}
+
+var opcodesByName = func() map[string]Opcode {
+	m := make(map[string]Opcode, len(opcodeNames))
+	for op, name := range opcodeNames {
+		m[name] = Opcode(op)
+	}
+	delete(m, opcodeNames[OpInvalid])
+	return m
+}()
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+	return "unknown"
+}
+
+type OperandKind uint8
+
+const (
+	/* An 8 bit register, Name */
+	OperandRegister OperandKind = iota
+	/* A 16 bit register pair, Name */
+	OperandRegisterPair
+	/* An 8 bit value, including bit numbers */
+	OperandImmediate8
+	/* A 16 bit value */
+	OperandImmediate16
+	/* A branch target, or a memory operand at a fixed address */
+	OperandAddress
+	/* A branch condition, Name */
+	OperandCondition
+	/* A memory operand addressed by register Name; "c" for the 0xff00+c of 0xe2 and 0xf2 */
+	OperandIndirect
+)
+
+type Operand struct {
+	Kind OperandKind
+	/* Lowercase register, pair or condition name */
+	Name string
+	/* For immediates and addresses */
+	Value uint16
+}
+
+/* An instruction as an opcode and typed operands, independent of any assembler's spelling */
+type Instruction struct {
+	Op       Opcode
+	Operands []Operand
+}
+
+/*
+ * Returns i in structured form. Instructions that failed to decode, Z80-only
+ * ones, and the signed stack pointer offsets of add sp, e and ld hl, sp+e are
+ * not representable and return false.
+ */
+func (i *GBInstruction) AST() (*Instruction, bool) {
+	op, ok := opcodesByName[i.Op()]
+	if !ok || i.Instruction[0] == 0xe8 || i.Instruction[0] == 0xf8 {
+		return nil, false
+	}
+	ast := &Instruction{Op: op}
+	target, branch := branchTarget(i)
+	for n, token := range i.Operands() {
+		if branch && n == len(i.Operands())-1 {
+			ast.Operands = append(ast.Operands, Operand{Kind: OperandAddress, Value: uint16(target)})
+			continue
+		}
+		operand, ok := parseOperand(token)
+		if !ok {
+			return nil, false
+		}
+		ast.Operands = append(ast.Operands, operand)
+	}
+	return ast, true
+}
+
+func parseOperand(token string) (Operand, bool) {
+	if inner, ok := strings.CutPrefix(token, "["); ok {
+		inner = strings.TrimSuffix(inner, "]")
+		if inner == "0xff00 + C" {
+			return Operand{Kind: OperandIndirect, Name: "c"}, true
+		}
+		if value, ok := parseHex(inner); ok {
+			return Operand{Kind: OperandAddress, Value: value}, true
+		}
+		if _, ok := parseRegister(inner); ok {
+			return Operand{Kind: OperandIndirect, Name: inner}, true
+		}
+		return Operand{}, false
+	}
+	for _, cond := range conditions {
+		if token == cond {
+			return Operand{Kind: OperandCondition, Name: strings.ToLower(cond)}, true
+		}
+	}
+	if kind, ok := parseRegister(token); ok {
+		return Operand{Kind: kind, Name: token}, true
+	}
+	if value, ok := parseHex(token); ok {
+		if len(token) > len("0x00") {
+			return Operand{Kind: OperandImmediate16, Value: value}, true
+		}
+		return Operand{Kind: OperandImmediate8, Value: value}, true
+	}
+	if value, err := strconv.ParseUint(token, 10, 8); err == nil {
+		return Operand{Kind: OperandImmediate8, Value: uint16(value)}, true
+	}
+	return Operand{}, false
+}
+
+func parseRegister(token string) (OperandKind, bool) {
+	switch token {
+	case "a", "b", "c", "d", "e", "h", "l":
+		return OperandRegister, true
+	case "af", "bc", "de", "hl", "sp":
+		return OperandRegisterPair, true
+	}
+	return 0, false
+}
+
+func parseHex(token string) (uint16, bool) {
+	digits, ok := strings.CutPrefix(token, "0x")
+	if !ok {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(digits, 16, 16)
+	return uint16(value), err == nil
+}
@@ -0,0 +1,75 @@
+package gobjdump
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestAST(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		want *Instruction
+	}{
+		{"ld a, [nn]", []uint8{0xfa, 0x34, 0x12}, &Instruction{Op: OpLD, Operands: []Operand{
+			{Kind: OperandRegister, Name: "a"},
+			{Kind: OperandAddress, Value: 0x1234},
+		}}},
+		{"ld hl, nn", []uint8{0x21, 0x34, 0x12}, &Instruction{Op: OpLD, Operands: []Operand{
+			{Kind: OperandRegisterPair, Name: "hl"},
+			{Kind: OperandImmediate16, Value: 0x1234},
+		}}},
+		{"ld a, n", []uint8{0x3e, 0x00}, &Instruction{Op: OpLD, Operands: []Operand{
+			{Kind: OperandRegister, Name: "a"},
+			{Kind: OperandImmediate8, Value: 0x00},
+		}}},
+		{"ldi [hl], a", []uint8{0x22}, &Instruction{Op: OpLDI, Operands: []Operand{
+			{Kind: OperandIndirect, Name: "hl"},
+			{Kind: OperandRegister, Name: "a"},
+		}}},
+		{"ld [c], a", []uint8{0xe2}, &Instruction{Op: OpLD, Operands: []Operand{
+			{Kind: OperandIndirect, Name: "c"},
+			{Kind: OperandRegister, Name: "a"},
+		}}},
+		{"ldh [n], a", []uint8{0xe0, 0x40}, &Instruction{Op: OpLD, Operands: []Operand{
+			{Kind: OperandAddress, Value: 0xff40},
+			{Kind: OperandRegister, Name: "a"},
+		}}},
+		{"jr nz, e", []uint8{0x20, 0xfe}, &Instruction{Op: OpJR, Operands: []Operand{
+			{Kind: OperandCondition, Name: "nz"},
+			{Kind: OperandAddress, Value: 0x0150},
+		}}},
+		{"bit 7, [hl]", []uint8{0xcb, 0x7e}, &Instruction{Op: OpBIT, Operands: []Operand{
+			{Kind: OperandImmediate8, Value: 7},
+			{Kind: OperandIndirect, Name: "hl"},
+		}}},
+		{"rst", []uint8{0xff}, &Instruction{Op: OpRST, Operands: []Operand{
+			{Kind: OperandAddress, Value: 0x38},
+		}}},
+		{"nop", []uint8{0x00}, &Instruction{Op: OpNOP}},
+		{"add sp, e", []uint8{0xe8, 0xfd}, nil},
+		{"illegal", []uint8{0xd3}, nil},
+		{"truncated", []uint8{0xfa, 0x34}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			ast, ok := i.AST()
+			if ok != (tt.want != nil) {
+				t.Fatalf("ok = %v, want %v", ok, tt.want != nil)
+			}
+			if !reflect.DeepEqual(ast, tt.want) {
+				t.Errorf("got %+v, want %+v", ast, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpcodeString(t *testing.T) {
+	for op, want := range map[Opcode]string{OpLD: "ld", OpSWAP: "swap", OpInvalid: "invalid", 200: "unknown"} {
+		if got := op.String(); got != want {
+			t.Errorf("%d: got %q, want %q", op, got, want)
+		}
+	}
+}
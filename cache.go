@@ -0,0 +1,86 @@
+package gobjdump
+
+import "bytes"
+
+/*
+ * Memoizes decodes by instruction bytes, for tools that decode the same few
+ * instructions over and over, such as a stepper redrawing the current line.
+ * Not safe for concurrent use.
+ */
+type DecodeCache struct {
+	Target CPU
+	cache  map[string]*GBInstruction
+}
+
+func NewDecodeCache() *DecodeCache {
+	return &DecodeCache{cache: make(map[string]*GBInstruction)}
+}
+
+/*
+ * Decodes the instruction at the start of data like DecodeBytes. The result
+ * is the caller's own copy; changing it does not affect the cache.
+ */
+func (c *DecodeCache) Decode(data []uint8, addr uint32) (*GBInstruction, int) {
+	key, ok := c.key(data)
+	if !ok {
+		gbInstruction, next := DecodeInstructionFor(bytes.NewReader(data), addr, c.Target)
+		return gbInstruction, int(next - addr)
+	}
+	cached, ok := c.cache[key]
+	if !ok {
+		cached, _ = DecodeInstructionFor(bytes.NewReader(data), 0, c.Target)
+		c.cache[key] = cached
+	}
+	gbInstruction := cloneInstruction(cached)
+	gbInstruction.Addr = addr
+	gbInstruction.ResolvedTarget = nil
+	if target, ok := resolvedTarget(gbInstruction); ok {
+		gbInstruction.ResolvedTarget = &target
+	}
+	return gbInstruction, gbInstruction.Len()
+}
+
+/*
+ * Returns the bytes of the instruction at the start of data as a cache key:
+ * exactly its bytes on the SM83, whose lengths are known from the opcode, and
+ * up to the longest Z80 instruction otherwise. An instruction cut short by
+ * the end of data is not cached.
+ */
+func (c *DecodeCache) key(data []uint8) (string, bool) {
+	if len(data) == 0 {
+		return "", false
+	}
+	n := min(len(data), 4)
+	if c.Target == TargetSM83 {
+		n = lengthTable[data[0]]
+		if data[0] == 0xcb && len(data) > 1 {
+			n = lengthTableCB[data[1]]
+		}
+		if n > len(data) {
+			return "", false
+		}
+	}
+	return string(data[:n]), true
+}
+
+/* Returns a copy of i sharing no mutable storage with it, unlinked from its neighbours */
+func cloneInstruction(i *GBInstruction) *GBInstruction {
+	c := *i
+	c.Prev, c.Next, c.Provenance = nil, nil, nil
+	c.Instruction = append([]uint8(nil), i.Instruction...)
+	c.Mnemonic = append([]string(nil), i.Mnemonic...)
+	c.Comments = append([]string(nil), i.Comments...)
+	if i.ResolvedTarget != nil {
+		target := *i.ResolvedTarget
+		c.ResolvedTarget = &target
+	}
+	if i.Imm != nil {
+		imm := *i.Imm
+		c.Imm = &imm
+	}
+	if i.Displacement != nil {
+		disp := *i.Displacement
+		c.Displacement = &disp
+	}
+	return &c
+}
@@ -0,0 +1,92 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []uint8
+		addr   uint32
+		target CPU
+	}{
+		{"ld a, n", []uint8{0x3e, 0x12, 0x00}, 0x0150, TargetSM83},
+		{"jr", []uint8{0x18, 0xfe}, 0x0150, TargetSM83},
+		{"jr elsewhere", []uint8{0x18, 0xfe}, 0x4000, TargetSM83},
+		{"call", []uint8{0xcd, 0x00, 0x02}, 0x0150, TargetSM83},
+		{"cb", []uint8{0xcb, 0x37}, 0x0150, TargetSM83},
+		{"illegal", []uint8{0xd3}, 0x0150, TargetSM83},
+		{"truncated", []uint8{0xc3, 0x50}, 0x0150, TargetSM83},
+		{"djnz", []uint8{0x10, 0xfe, 0x00, 0x00}, 0x0150, TargetZ80},
+		{"djnz at the end", []uint8{0x10, 0xfe}, 0x0150, TargetZ80},
+	}
+	c := NewDecodeCache()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Target = tt.target
+			want, wantNext := DecodeInstructionFor(bytes.NewReader(tt.data), tt.addr, tt.target)
+			/* The first decode fills the cache, the second is served from it */
+			for pass := 0; pass < 2; pass++ {
+				got, n := c.Decode(tt.data, tt.addr)
+				if !got.Equal(want) || uint32(n) != wantNext-tt.addr {
+					t.Errorf("pass %d: got %v over %d bytes, want %v over %d", pass, got, n, want, wantNext-tt.addr)
+				}
+				if (got.ResolvedTarget == nil) != (want.ResolvedTarget == nil) ||
+					got.ResolvedTarget != nil && *got.ResolvedTarget != *want.ResolvedTarget {
+					t.Errorf("pass %d: ResolvedTarget %v, want %v", pass, got.ResolvedTarget, want.ResolvedTarget)
+				}
+			}
+		})
+	}
+}
+
+/* Changing a returned instruction leaves the cached one untouched */
+func TestDecodeCacheCopies(t *testing.T) {
+	c := NewDecodeCache()
+	data := []uint8{0xcd, 0x00, 0x02}
+	first, _ := c.Decode(data, 0x0150)
+	first.Instruction[1] = 0xff
+	first.Mnemonic[0] = "jp"
+	*first.Imm = 0xffff
+	*first.ResolvedTarget = 0xffff
+	first.Comments = append(first.Comments, "changed")
+
+	second, _ := c.Decode(data, 0x0150)
+	if got := second.ToStr(); got != "0x0150: cd0002       call   0x0200" {
+		t.Errorf("cached instruction changed: %q", got)
+	}
+	if *second.Imm != 0x0200 || *second.ResolvedTarget != 0x0200 || len(second.Comments) != 0 {
+		t.Errorf("cached instruction changed: Imm %#x, ResolvedTarget %#x, Comments %q",
+			*second.Imm, *second.ResolvedTarget, second.Comments)
+	}
+	if second.Instruction[1] != 0x00 || data[1] != 0x00 {
+		t.Errorf("bytes changed: cached % x, data % x", second.Instruction, data)
+	}
+}
+
+func BenchmarkDecodeBytes(b *testing.B) {
+	program := benchmarkProgram()
+	b.SetBytes(int64(len(program)))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		for addr := 0; addr < len(program); {
+			_, size := DecodeBytes(program[addr:], uint32(addr))
+			addr += size
+		}
+	}
+}
+
+func BenchmarkDecodeCache(b *testing.B) {
+	program := benchmarkProgram()
+	b.SetBytes(int64(len(program)))
+	b.ReportAllocs()
+	c := NewDecodeCache()
+	for n := 0; n < b.N; n++ {
+		for addr := 0; addr < len(program); {
+			_, size := c.Decode(program[addr:], uint32(addr))
+			addr += size
+		}
+	}
+}
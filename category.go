@@ -0,0 +1,106 @@
+package gobjdump
+
+/* A coarse grouping of instructions, e.g. for syntax highlighting */
+type Category uint8
+
+const (
+	/* The instruction failed to decode */
+	CategoryNone Category = iota
+	CategoryLoad
+	CategoryArithmetic
+	CategoryLogic
+	CategoryRotateShift
+	CategoryBit
+	CategoryControlFlow
+	CategoryStack
+	CategoryMisc
+)
+
+var categoryNames = [...]string{
+	CategoryNone:        "none",
+	CategoryLoad:        "load",
+	CategoryArithmetic:  "arithmetic",
+	CategoryLogic:       "logic",
+	CategoryRotateShift: "rotate/shift",
+	CategoryBit:         "bit",
+	CategoryControlFlow: "control flow",
+	CategoryStack:       "stack",
+	CategoryMisc:        "misc",
+}
+
+func (c Category) String() string {
+	if int(c) < len(categoryNames) {
+		return categoryNames[c]
+	}
+	return "unknown"
+}
+
+/* Keyed by mnemonic; anything decoded but missing here is CategoryMisc */
+var categories = map[string]Category{
+	"ld":   CategoryLoad,
+	"ldi":  CategoryLoad,
+	"ldd":  CategoryLoad,
+	"ldir": CategoryLoad,
+	"lddr": CategoryLoad,
+	"ex":   CategoryLoad,
+	"exx":  CategoryLoad,
+	"in":   CategoryLoad,
+	"out":  CategoryLoad,
+
+	"add": CategoryArithmetic,
+	"adc": CategoryArithmetic,
+	"sub": CategoryArithmetic,
+	"sbc": CategoryArithmetic,
+	"inc": CategoryArithmetic,
+	"dec": CategoryArithmetic,
+	"cp":  CategoryArithmetic,
+	"daa": CategoryArithmetic,
+	"neg": CategoryArithmetic,
+
+	"and": CategoryLogic,
+	"or":  CategoryLogic,
+	"xor": CategoryLogic,
+	"cpl": CategoryLogic,
+
+	"rlca": CategoryRotateShift,
+	"rrca": CategoryRotateShift,
+	"rla":  CategoryRotateShift,
+	"rra":  CategoryRotateShift,
+	"rlc":  CategoryRotateShift,
+	"rrc":  CategoryRotateShift,
+	"rl":   CategoryRotateShift,
+	"rr":   CategoryRotateShift,
+	"sla":  CategoryRotateShift,
+	"sra":  CategoryRotateShift,
+	"sll":  CategoryRotateShift,
+	"srl":  CategoryRotateShift,
+	"swap": CategoryRotateShift,
+	"rld":  CategoryRotateShift,
+	"rrd":  CategoryRotateShift,
+
+	"bit": CategoryBit,
+	"res": CategoryBit,
+	"set": CategoryBit,
+
+	"jp":   CategoryControlFlow,
+	"jr":   CategoryControlFlow,
+	"djnz": CategoryControlFlow,
+	"call": CategoryControlFlow,
+	"ret":  CategoryControlFlow,
+	"reti": CategoryControlFlow,
+	"retn": CategoryControlFlow,
+	"rst":  CategoryControlFlow,
+
+	"push": CategoryStack,
+	"pop":  CategoryStack,
+}
+
+func category(i *GBInstruction) Category {
+	if i.Err != nil || len(i.Mnemonic) == 0 {
+		return CategoryNone
+	}
+	if c, ok := categories[i.Mnemonic[0]]; ok {
+		return c
+	}
+	return CategoryMisc
+}
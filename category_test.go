@@ -0,0 +1,50 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []uint8
+		target CPU
+		want   Category
+	}{
+		{"push bc", []uint8{0xc5}, TargetSM83, CategoryStack},
+		{"pop af", []uint8{0xf1}, TargetSM83, CategoryStack},
+		{"xor a", []uint8{0xaf}, TargetSM83, CategoryLogic},
+		{"cpl", []uint8{0x2f}, TargetSM83, CategoryLogic},
+		{"jp nn", []uint8{0xc3, 0x50, 0x01}, TargetSM83, CategoryControlFlow},
+		{"rst", []uint8{0xff}, TargetSM83, CategoryControlFlow},
+		{"ld a, b", []uint8{0x78}, TargetSM83, CategoryLoad},
+		{"ldi", []uint8{0x22}, TargetSM83, CategoryLoad},
+		{"cp n", []uint8{0xfe, 0x10}, TargetSM83, CategoryArithmetic},
+		{"swap a", []uint8{0xcb, 0x37}, TargetSM83, CategoryRotateShift},
+		{"bit 7, h", []uint8{0xcb, 0x7c}, TargetSM83, CategoryBit},
+		{"nop", []uint8{0x00}, TargetSM83, CategoryMisc},
+		{"halt", []uint8{0x76}, TargetSM83, CategoryMisc},
+		{"illegal", []uint8{0xd3}, TargetSM83, CategoryNone},
+		{"truncated", []uint8{0xc3, 0x50}, TargetSM83, CategoryNone},
+		{"djnz", []uint8{0x10, 0xfe}, TargetZ80, CategoryControlFlow},
+		{"exx", []uint8{0xd9}, TargetZ80, CategoryLoad},
+		{"sll a", []uint8{0xcb, 0x37}, TargetZ80, CategoryRotateShift},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstructionFor(bytes.NewReader(tt.data), 0x0150, tt.target)
+			if i.Category != tt.want {
+				t.Errorf("got %v, want %v", i.Category, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategoryString(t *testing.T) {
+	for c, want := range map[Category]string{CategoryStack: "stack", CategoryRotateShift: "rotate/shift", CategoryNone: "none", 99: "unknown"} {
+		if got := c.String(); got != want {
+			t.Errorf("%d: got %q, want %q", c, got, want)
+		}
+	}
+}
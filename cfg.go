@@ -0,0 +1,161 @@
+package gobjdump
+
+import (
+	"fmt"
+	"strings"
+)
+
+type EdgeKind uint8
+
+const (
+	/* Control runs on into the next block */
+	EdgeFallThrough EdgeKind = iota
+	/* The taken side of a conditional branch */
+	EdgeBranch
+	/* An unconditional jump */
+	EdgeJump
+)
+
+type Edge struct {
+	To   uint32
+	Kind EdgeKind
+}
+
+/* A run of instructions only entered at the first and only left after the last */
+type BasicBlock struct {
+	/* Address of the first instruction and of the byte after the last */
+	Start, End uint32
+	Insns      []*GBInstruction
+	/* Branch and jump edges may lead outside the graph */
+	Succs []Edge
+}
+
+type CFG struct {
+	/* Ordered by start address */
+	Blocks  []*BasicBlock
+	byStart map[uint32]*BasicBlock
+}
+
+/* Returns the block starting at addr, or nil */
+func (g *CFG) Block(addr uint32) *BasicBlock {
+	return g.byStart[addr]
+}
+
+/*
+ * Partitions insns, which must be in ascending address order, into basic
+ * blocks. A block starts at the first instruction, at every branch or jump
+ * target that falls on an instruction boundary, after a gap and after every
+ * branch, jump or return. Calls do not end a block; their targets belong to
+ * another routine.
+ */
+func BuildCFG(insns []*GBInstruction) (*CFG, error) {
+	g := &CFG{byStart: make(map[uint32]*BasicBlock)}
+	if len(insns) == 0 {
+		return g, nil
+	}
+
+	boundaries := make(map[uint32]bool, len(insns))
+	for n, i := range insns {
+		if n > 0 && i.Addr <= insns[n-1].Addr {
+			return nil, fmt.Errorf("instructions out of order at 0x%04x", i.Addr)
+		}
+		boundaries[i.Addr] = true
+	}
+	leaders := map[uint32]bool{insns[0].Addr: true}
+	for n, i := range insns {
+		if (i.Flow == FlowBranch || i.Flow == FlowJump) && i.ResolvedTarget != nil && boundaries[*i.ResolvedTarget] {
+			leaders[*i.ResolvedTarget] = true
+		}
+		if n+1 < len(insns) {
+			next := insns[n+1]
+			if next.Addr != instructionEnd(i) || i.Flow == FlowBranch || !i.Flow.FallsThrough() {
+				leaders[next.Addr] = true
+			}
+		}
+	}
+
+	var block *BasicBlock
+	for _, i := range insns {
+		if leaders[i.Addr] {
+			block = &BasicBlock{Start: i.Addr}
+			g.Blocks = append(g.Blocks, block)
+			g.byStart[i.Addr] = block
+		}
+		block.Insns = append(block.Insns, i)
+		block.End = instructionEnd(i)
+	}
+
+	for _, block := range g.Blocks {
+		last := block.Insns[len(block.Insns)-1]
+		switch last.Flow {
+		case FlowJump:
+			if last.ResolvedTarget != nil {
+				block.Succs = append(block.Succs, Edge{To: *last.ResolvedTarget, Kind: EdgeJump})
+			}
+			continue
+		case FlowReturn, FlowIndirectJump:
+			continue
+		case FlowBranch:
+			if last.ResolvedTarget != nil {
+				block.Succs = append(block.Succs, Edge{To: *last.ResolvedTarget, Kind: EdgeBranch})
+			}
+		}
+		if g.byStart[block.End] != nil {
+			block.Succs = append(block.Succs, Edge{To: block.End, Kind: EdgeFallThrough})
+		}
+	}
+	return g, nil
+}
+
+func instructionEnd(i *GBInstruction) uint32 {
+	return i.Addr + uint32(len(i.Instruction))
+}
+
+/* Escapes backslashes and double quotes in s for a double-quoted DOT string */
+func dotEscape(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	return strings.ReplaceAll(s, "\"", "\\\"")
+}
+
+func dotNode(addr uint32) string {
+	return fmt.Sprintf("b_%04x", addr)
+}
+
+/*
+ * Renders g as a Graphviz digraph with one box per block. The taken and
+ * not-taken edges of a conditional branch are green and red; targets outside
+ * the graph are drawn as dashed address nodes.
+ */
+func (g *CFG) ToDOT() string {
+	var b strings.Builder
+	b.WriteString("digraph cfg {\n")
+	b.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	external := make(map[uint32]bool)
+	for _, block := range g.Blocks {
+		var label strings.Builder
+		for _, i := range block.Insns {
+			label.WriteString(dotEscape(i.ToStr()))
+			label.WriteString("\\l")
+		}
+		fmt.Fprintf(&b, "\t%s [label=\"%s\"];\n", dotNode(block.Start), label.String())
+	}
+	for _, block := range g.Blocks {
+		conditional := block.Insns[len(block.Insns)-1].Flow == FlowBranch
+		for _, edge := range block.Succs {
+			if g.byStart[edge.To] == nil && !external[edge.To] {
+				external[edge.To] = true
+				fmt.Fprintf(&b, "\t%s [label=\"0x%04x\", style=dashed];\n", dotNode(edge.To), edge.To)
+			}
+			attrs := ""
+			switch {
+			case edge.Kind == EdgeBranch:
+				attrs = " [color=green]"
+			case conditional && edge.Kind == EdgeFallThrough:
+				attrs = " [color=red]"
+			}
+			fmt.Fprintf(&b, "\t%s -> %s%s;\n", dotNode(block.Start), dotNode(edge.To), attrs)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package gobjdump
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBuildCFGLoop(t *testing.T) {
+	program := []uint8{
+		0x06, 0x10, /* 0x0150: ld b, 0x10 */
+		0x05,       /* 0x0152: dec b */
+		0x20, 0xfd, /* 0x0153: jr nz, 0x0152 */
+		0xcd, 0x00, 0x02, /* 0x0155: call 0x0200 */
+		0xc9, /* 0x0158: ret */
+	}
+	insns, err := Disassemble(bytes.NewReader(program), 0x0150, 0x0159)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := BuildCFG(insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		start, end uint32
+		succs      []Edge
+	}{
+		{0x0150, 0x0152, []Edge{{0x0152, EdgeFallThrough}}},
+		{0x0152, 0x0155, []Edge{{0x0152, EdgeBranch}, {0x0155, EdgeFallThrough}}},
+		{0x0155, 0x0159, nil},
+	}
+	if len(g.Blocks) != len(want) {
+		t.Fatalf("%d blocks, want %d", len(g.Blocks), len(want))
+	}
+	for n, block := range g.Blocks {
+		if block.Start != want[n].start || block.End != want[n].end || !reflect.DeepEqual(block.Succs, want[n].succs) {
+			t.Errorf("block %d: [0x%04x, 0x%04x) %v, want [0x%04x, 0x%04x) %v",
+				n, block.Start, block.End, block.Succs, want[n].start, want[n].end, want[n].succs)
+		}
+		if g.Block(block.Start) != block {
+			t.Errorf("Block(0x%04x) does not return block %d", block.Start, n)
+		}
+	}
+	if g.Block(0x0153) != nil {
+		t.Error("Block(0x0153) returned a block for an address inside one")
+	}
+}
+
+func TestBuildCFGBlockEnds(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []uint8
+		starts []uint32
+	}{
+		{"jump ends a block", []uint8{0x18, 0x00, 0x00}, []uint32{0x0150, 0x0152}},
+		{"return ends a block", []uint8{0xc9, 0x00}, []uint32{0x0150, 0x0151}},
+		{"jp hl ends a block", []uint8{0xe9, 0x00}, []uint32{0x0150, 0x0151}},
+		{"call does not", []uint8{0xcd, 0x00, 0x02, 0x00}, []uint32{0x0150}},
+		{"mid-instruction target", []uint8{0x3e, 0x18, 0x18, 0xfd}, []uint32{0x0150}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, _ := Disassemble(bytes.NewReader(tt.data), 0x0150, 0x0150+uint32(len(tt.data)))
+			g, err := BuildCFG(insns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var starts []uint32
+			for _, block := range g.Blocks {
+				starts = append(starts, block.Start)
+			}
+			if !reflect.DeepEqual(starts, tt.starts) {
+				t.Errorf("blocks start at %x, want %x", starts, tt.starts)
+			}
+		})
+	}
+}
+
+func TestBuildCFGOutOfOrder(t *testing.T) {
+	insns, _ := Disassemble(bytes.NewReader([]uint8{0x00, 0x00}), 0x0150, 0x0152)
+	insns[0], insns[1] = insns[1], insns[0]
+	if _, err := BuildCFG(insns); err == nil {
+		t.Error("want an error for instructions out of order")
+	}
+}
+
+func TestCFGToDOT(t *testing.T) {
+	program := []uint8{
+		0x05,       /* 0x0150: dec b */
+		0x20, 0xfd, /* 0x0151: jr nz, 0x0150 */
+		0xc3, 0x00, 0x02, /* 0x0153: jp 0x0200 */
+	}
+	insns, _ := Disassemble(bytes.NewReader(program), 0x0150, 0x0156)
+	g, err := BuildCFG(insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "digraph cfg {\n" +
+		"\tnode [shape=box, fontname=\"monospace\"];\n" +
+		"\tb_0150 [label=\"0x0150: 05           dec    b\\l0x0151: 20fd         jr     NZ, -3\\l\"];\n" +
+		"\tb_0153 [label=\"0x0153: c30002       jp     0x0200\\l\"];\n" +
+		"\tb_0150 -> b_0150 [color=green];\n" +
+		"\tb_0150 -> b_0153 [color=red];\n" +
+		"\tb_0200 [label=\"0x0200\", style=dashed];\n" +
+		"\tb_0153 -> b_0200;\n" +
+		"}\n"
+	if got := g.ToDOT(); got != want {
+		t.Errorf("ToDOT:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDOTEscape(t *testing.T) {
+	if got, want := dotEscape(`say "hi" \ bye`), `say \"hi\" \\ bye`; got != want {
+		t.Errorf("dotEscape = %q, want %q", got, want)
+	}
+}
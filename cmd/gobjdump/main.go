@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/SrsBusiness/gobjdump"
+)
+
+func main() {
+	os.Exit(gobjdump.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
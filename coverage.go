@@ -0,0 +1,63 @@
+package gobjdump
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type OpcodeStatus uint8
+
+const (
+	/* Decodes to an instruction */
+	OpcodeValid OpcodeStatus = iota
+	/* Deliberately rejected as illegal */
+	OpcodeIllegal
+	/* Neither: unimplemented, or a slot the decoder falls through */
+	OpcodeGap
+)
+
+type OpcodeCoverage struct {
+	Primary [256]OpcodeStatus
+	/* Indexed by the byte after the 0xcb prefix */
+	CB [256]OpcodeStatus
+}
+
+/*
+ * Decodes every primary and 0xcb-prefixed opcode, with zero immediates, and
+ * classifies the result. A correct decoder reports no OpcodeGap.
+ */
+func CoverageReport() *OpcodeCoverage {
+	c := &OpcodeCoverage{}
+	for op := 0; op < 256; op++ {
+		c.Primary[op] = opcodeStatus([]uint8{uint8(op), 0x00, 0x00})
+		c.CB[op] = opcodeStatus([]uint8{0xcb, uint8(op)})
+	}
+	return c
+}
+
+func opcodeStatus(instruction []uint8) OpcodeStatus {
+	gbInstruction, _ := DecodeInstruction(bytes.NewReader(instruction), 0)
+	switch {
+	case gbInstruction.Err == nil && len(gbInstruction.Mnemonic) > 0:
+		return OpcodeValid
+	case IsIllegal(gbInstruction.Err):
+		return OpcodeIllegal
+	}
+	return OpcodeGap
+}
+
+/* Returns every gap in c, e.g. "0xd3" or "0xcb 0x36" */
+func (c *OpcodeCoverage) Gaps() []string {
+	var gaps []string
+	for op, status := range c.Primary {
+		if status == OpcodeGap {
+			gaps = append(gaps, fmt.Sprintf("0x%02x", op))
+		}
+	}
+	for op, status := range c.CB {
+		if status == OpcodeGap {
+			gaps = append(gaps, fmt.Sprintf("0xcb 0x%02x", op))
+		}
+	}
+	return gaps
+}
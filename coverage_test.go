@@ -0,0 +1,37 @@
+package gobjdump
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCoverageReport(t *testing.T) {
+	c := CoverageReport()
+	if gaps := c.Gaps(); len(gaps) != 0 {
+		t.Errorf("gaps in the decoder: %v", gaps)
+	}
+	for op, status := range c.Primary {
+		want := OpcodeValid
+		if slices.Contains(unusedSM83Opcodes, uint8(op)) {
+			want = OpcodeIllegal
+		}
+		if status != want {
+			t.Errorf("0x%02x: status %d, want %d", op, status, want)
+		}
+	}
+	for op, status := range c.CB {
+		if status != OpcodeValid {
+			t.Errorf("0xcb 0x%02x: status %d, want valid", op, status)
+		}
+	}
+}
+
+func TestCoverageGaps(t *testing.T) {
+	c := &OpcodeCoverage{}
+	c.Primary[0xd3] = OpcodeGap
+	c.Primary[0x10] = OpcodeIllegal
+	c.CB[0x36] = OpcodeGap
+	if got, want := c.Gaps(), []string{"0xd3", "0xcb 0x36"}; !slices.Equal(got, want) {
+		t.Errorf("Gaps = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package gobjdump
+
+/*
+ * SM83 timings in machine cycles (4 clocks each). Conditional branches are
+ * listed at their not-taken cost, with the taken cost in cyclesTaken. The
+ * 0xcb prefix is costed by cbCycles, illegal opcodes are 0.
+ */
+var cycles = [256]int{
+	1, 3, 2, 2, 1, 1, 2, 1, 5, 2, 2, 2, 1, 1, 2, 1, /* 0x00 */
+	1, 3, 2, 2, 1, 1, 2, 1, 3, 2, 2, 2, 1, 1, 2, 1, /* 0x10 */
+	2, 3, 2, 2, 1, 1, 2, 1, 2, 2, 2, 2, 1, 1, 2, 1, /* 0x20 */
+	2, 3, 2, 2, 3, 3, 3, 1, 2, 2, 2, 2, 1, 1, 2, 1, /* 0x30 */
+	1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 2, 1, /* 0x40 */
+	1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 2, 1, /* 0x50 */
+	1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 2, 1, /* 0x60 */
+	2, 2, 2, 2, 2, 2, 1, 2, 1, 1, 1, 1, 1, 1, 2, 1, /* 0x70 */
+	1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 2, 1, /* 0x80 */
+	1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 2, 1, /* 0x90 */
+	1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 2, 1, /* 0xa0 */
+	1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 2, 1, /* 0xb0 */
+	2, 3, 3, 4, 3, 4, 2, 4, 2, 4, 3, 1, 3, 6, 2, 4, /* 0xc0 */
+	2, 3, 3, 0, 3, 4, 2, 4, 2, 4, 3, 0, 3, 0, 2, 4, /* 0xd0 */
+	3, 3, 2, 0, 0, 4, 2, 4, 4, 1, 4, 0, 0, 0, 2, 4, /* 0xe0 */
+	3, 3, 2, 1, 0, 4, 2, 4, 3, 2, 4, 1, 0, 0, 2, 4, /* 0xf0 */
+}
+
+var cyclesTaken = map[uint8]int{
+	/* jr cc, e */
+	0x20: 3, 0x28: 3, 0x30: 3, 0x38: 3,
+	/* ret cc */
+	0xc0: 5, 0xc8: 5, 0xd0: 5, 0xd8: 5,
+	/* jp cc, nn */
+	0xc2: 4, 0xca: 4, 0xd2: 4, 0xda: 4,
+	/* call cc, nn */
+	0xc4: 6, 0xcc: 6, 0xd4: 6, 0xdc: 6,
+}
+
+/* Cost of a 0xcb-prefixed opcode, prefix included: [hl] operands need extra memory cycles */
+func cbCycles(op uint8) int {
+	switch {
+	case op&0x07 != 0x06:
+		return 2
+	case op&0xc0 == 0x40:
+		/* bit b, [hl] only reads */
+		return 3
+	default:
+		return 4
+	}
+}
+
+/* Fills in the SM83 timing of a successfully decoded instruction */
+func setCycles(i *GBInstruction) {
+	if i.Err != nil || len(i.Instruction) == 0 {
+		return
+	}
+	op := i.Instruction[0]
+	if op == 0xcb {
+		i.Cycles = cbCycles(i.Instruction[1])
+		return
+	}
+	i.Cycles = cycles[op]
+	if taken, ok := cyclesTaken[op]; ok {
+		i.CyclesTaken = taken
+		i.CyclesNotTaken = cycles[op]
+	}
+}
@@ -0,0 +1,77 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCycles(t *testing.T) {
+	tests := []struct {
+		name                    string
+		data                    []uint8
+		cycles, taken, notTaken int
+	}{
+		{"nop", []uint8{0x00}, 1, 0, 0},
+		{"ld bc, nn", []uint8{0x01, 0x34, 0x12}, 3, 0, 0},
+		{"jp nn", []uint8{0xc3, 0x50, 0x01}, 4, 0, 0},
+		{"call nn", []uint8{0xcd, 0x50, 0x01}, 6, 0, 0},
+		{"ret", []uint8{0xc9}, 4, 0, 0},
+		{"jr nz", []uint8{0x20, 0xfe}, 2, 3, 2},
+		{"jp nz", []uint8{0xc2, 0x50, 0x01}, 3, 4, 3},
+		{"call nz", []uint8{0xc4, 0x50, 0x01}, 3, 6, 3},
+		{"ret nz", []uint8{0xc0}, 2, 5, 2},
+		{"rlc b", []uint8{0xcb, 0x00}, 2, 0, 0},
+		{"illegal", []uint8{0xd3}, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if i.Cycles != tt.cycles || i.CyclesTaken != tt.taken || i.CyclesNotTaken != tt.notTaken {
+				t.Errorf("cycles %d taken %d not taken %d, want %d %d %d",
+					i.Cycles, i.CyclesTaken, i.CyclesNotTaken, tt.cycles, tt.taken, tt.notTaken)
+			}
+		})
+	}
+}
+
+func TestFormatCycles(t *testing.T) {
+	tests := []struct {
+		data []uint8
+		want string
+	}{
+		{[]uint8{0x00}, "0x0150: 00           nop ; 1 cycles"},
+		{[]uint8{0x20, 0xfe}, "0x0150: 20fe         jr     NZ, -2 ; 3/2 cycles"},
+		{[]uint8{0xd3}, "0x0150: d3           Illegal Instruction"},
+	}
+	for _, tt := range tests {
+		i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+		if got := i.ToStrWithOptions(FormatOptions{Cycles: true}); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+/* The [hl] forms of the 0xcb opcodes read memory, and all but bit write it back */
+func TestCBCycles(t *testing.T) {
+	for op := 0; op < 256; op++ {
+		i, _ := DecodeInstruction(bytes.NewReader([]uint8{0xcb, uint8(op)}), 0x0150)
+		if len(i.Mnemonic) == 0 || i.Err != nil {
+			t.Errorf("cb %02x: %q, %v", op, i.Mnemonic, i.Err)
+			continue
+		}
+		want := 2
+		if op&0x07 == 0x06 {
+			want = 4
+			if op&0xc0 == 0x40 {
+				want = 3
+			}
+		}
+		if i.Cycles != want {
+			t.Errorf("cb %02x (%s): %d cycles, want %d", op, i, i.Cycles, want)
+		}
+	}
+	i, _ := DecodeInstruction(bytes.NewReader([]uint8{0xcb, 0x7e}), 0x0150)
+	if got := i.ToStr(); got != "0x0150: cb7e         bit    7, [hl]" {
+		t.Errorf("cb 7e decoded as %q", got)
+	}
+}
@@ -0,0 +1,45 @@
+package gobjdump
+
+/*
+ * Decodes a stream of instructions into caller-owned GBInstructions, reusing
+ * their storage, for hot loops over large ROMs where DecodeInstruction's
+ * per-instruction allocations dominate.
+ */
+type Decoder struct {
+	r      Reader
+	addr   uint32
+	Target CPU
+}
+
+func NewDecoder(r Reader, addr uint32) *Decoder {
+	return NewDecoderFor(r, addr, TargetSM83)
+}
+
+/* Like NewDecoder, decoding target's instruction set */
+func NewDecoderFor(r Reader, addr uint32, target CPU) *Decoder {
+	return &Decoder{r: r, addr: addr, Target: target}
+}
+
+/* Points the decoder at a new stream, keeping its target */
+func (d *Decoder) Reset(r Reader, addr uint32) {
+	d.r = r
+	d.addr = addr
+}
+
+/* The address the next instruction will be decoded at */
+func (d *Decoder) Addr() uint32 {
+	return d.addr
+}
+
+/*
+ * Decodes the next instruction into dst, overwriting all of its fields. The
+ * Instruction, Mnemonic and Comments slices and the ResolvedTarget, Imm and
+ * Displacement pointers of dst are reused, so nothing previously read from
+ * dst may be retained.
+ * returns: false at the end of the stream, leaving dst unspecified
+ */
+func (d *Decoder) DecodeInto(dst *GBInstruction) bool {
+	next, ok := decodeInto(d.r, d.addr, d.Target, dst)
+	d.addr = next
+	return ok
+}
@@ -0,0 +1,47 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzDecodeInstruction(f *testing.F) {
+	for _, seed := range [][]uint8{
+		{0xcb, 0x00}, {0xcb, 0x37}, {0xcb, 0x7e}, {0xcb, 0xff}, {0xcb},
+		{0x10, 0x00},
+		{0xe8, 0x80},
+		{0xf8, 0x7f},
+		{0xc3}, {0xc3, 0x50},
+		{0xd3}, {0xdb}, {0xdd}, {0xe3}, {0xe4}, {0xeb}, {0xec}, {0xed}, {0xf4}, {0xfc}, {0xfd},
+		{0xed, 0xb0}, {0xed, 0x43, 0x34},
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []uint8) {
+		for _, target := range []CPU{TargetSM83, TargetZ80} {
+			r := bytes.NewReader(data)
+			consumed := 0
+			for addr := uint32(0x0100); ; {
+				i, next := DecodeInstructionFor(r, addr, target)
+				if i == nil {
+					break
+				}
+				if i.Err == nil && next <= addr {
+					t.Fatalf("%v: % x at 0x%04x decoded without advancing", target, data, addr)
+				}
+				if int(next-addr) != i.Len() {
+					t.Fatalf("%v: % x at 0x%04x advanced %d bytes for a %d byte instruction", target, data, addr, next-addr, i.Len())
+				}
+				_ = i.ToStr()
+				consumed += i.Len()
+				if consumed > len(data) {
+					t.Fatalf("%v: consumed %d bytes of % x", target, consumed, data)
+				}
+				if next == addr {
+					break
+				}
+				addr = next
+			}
+		}
+	})
+}
@@ -0,0 +1,508 @@
+package gobjdump
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []uint8
+		want     string
+		consumed int
+	}{
+		{"cb prefixed", []uint8{0xcb, 0x7e, 0x00}, "bit 7, [hl]", 2},
+		{"imm16", []uint8{0x01, 0x34, 0x12, 0x00}, "ld bc, 0x1234", 3},
+		{"one byte", []uint8{0x00, 0x00}, "nop", 1},
+		{"truncated imm16", []uint8{0xc3, 0x50}, "", 2},
+		{"empty", nil, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, consumed := DecodeBytes(tt.data, 0x0150)
+			if consumed != tt.consumed {
+				t.Errorf("consumed %d, want %d", consumed, tt.consumed)
+			}
+			if i == nil {
+				if tt.consumed != 0 {
+					t.Fatal("no instruction")
+				}
+				return
+			}
+			if consumed != len(i.Instruction) {
+				t.Errorf("consumed %d, but the instruction is % x", consumed, i.Instruction)
+			}
+			if tt.want != "" {
+				if got := strings.TrimSpace(i.Op() + " " + strings.Join(i.Operands(), ", ")); got != tt.want {
+					t.Errorf("decoded %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+/* 0xe0-0xf8 hold ldh, ld and add sp on the SM83 where the Z80 has its PO/PE/P/M branches */
+func TestDecodeSM83ParitySignSlots(t *testing.T) {
+	tests := []struct {
+		data []uint8
+		sm83 string
+		z80  string
+	}{
+		{[]uint8{0xe0, 0x44}, "ld [0xff44], a", "ret PO"},
+		{[]uint8{0xe2, 0x34, 0x12}, "ld [0xff00 + C], a", "jp PO, 0x1234"},
+		{[]uint8{0xf0, 0x44}, "ld a, [0xff44]", "ret P"},
+		{[]uint8{0xf2, 0x34, 0x12}, "ld a, [0xff00 + C]", "jp P, 0x1234"},
+		{[]uint8{0xe8, 0x02}, "add sp, 2", "ret PE"},
+		{[]uint8{0xea, 0x34, 0x12}, "ld [0x1234], a", "jp PE, 0x1234"},
+		{[]uint8{0xf8, 0x02}, "ld hl, sp+2", "ret M"},
+		{[]uint8{0xfa, 0x34, 0x12}, "ld a, [0x1234]", "jp M, 0x1234"},
+	}
+	for _, tt := range tests {
+		for _, target := range []CPU{TargetSM83, TargetZ80} {
+			want, wantFlow := tt.sm83, FlowSequential
+			if target == TargetZ80 {
+				want, wantFlow = tt.z80, FlowBranch
+			}
+			i, _ := DecodeInstructionFor(bytes.NewReader(tt.data), 0x0150, target)
+			if got := strings.TrimSpace(i.Op() + " " + strings.Join(i.Operands(), ", ")); got != want {
+				t.Errorf("target %d, % x: decoded %q, want %q", target, tt.data, got, want)
+			}
+			if i.Flow != wantFlow {
+				t.Errorf("target %d, % x: flow %v, want %v", target, tt.data, i.Flow, wantFlow)
+			}
+		}
+	}
+}
+
+func TestDecodeStopConsumesTwoBytes(t *testing.T) {
+	insns, err := Disassemble(bytes.NewReader([]uint8{0x10, 0x00, 0x3c}), 0x0150, 0x0153)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insns) != 2 {
+		t.Fatalf("decoded %d instructions, want 2", len(insns))
+	}
+	if stop := insns[0]; stop.Op() != "stop" || !bytes.Equal(stop.Instruction, []uint8{0x10, 0x00}) {
+		t.Errorf("first instruction %s, want stop over 10 00", stop)
+	}
+	if inc := insns[1]; inc.Addr != 0x0152 || inc.Op() != "inc" || strings.Join(inc.Operands(), ", ") != "a" {
+		t.Errorf("second instruction %s, want inc a at 0x0152", inc)
+	}
+}
+
+var errFlaky = errors.New("flaky device")
+
+/* Yields data, then fails every read with err */
+type failingReader struct {
+	data []uint8
+	err  error
+}
+
+func (f *failingReader) ReadByte() (byte, error) {
+	if len(f.data) == 0 {
+		return 0, f.err
+	}
+	b := f.data[0]
+	f.data = f.data[1:]
+	return b, nil
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, f.err
+	}
+	n := copy(p, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+func TestDecodeReadErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []uint8
+		err       error
+		wantNil   bool
+		wantType  Z80AsmErrorType
+		wantBytes []uint8
+	}{
+		{"eof before opcode", nil, io.EOF, true, 0, nil},
+		{"error before opcode", nil, errFlaky, false, Z80AsmErrorReadFailure, []uint8{}},
+		{"eof in operand", []uint8{0xc3}, io.EOF, false, Z80AsmErrorMalformedInstruction, []uint8{0xc3}},
+		{"error in operand", []uint8{0xc3}, errFlaky, false, Z80AsmErrorUnknown, []uint8{0xc3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(&failingReader{tt.data, tt.err}, 0x0150)
+			if tt.wantNil {
+				if i != nil {
+					t.Errorf("got %s, want the end of the stream", i.ToStr())
+				}
+				return
+			}
+			if i == nil {
+				t.Fatal("no instruction")
+			}
+			if !IsZ80AsmErrorType(i.Err, tt.wantType) {
+				t.Errorf("err = %v, want type %d", i.Err, tt.wantType)
+			}
+			if errors.Is(i.Err, errFlaky) != (tt.err == errFlaky) {
+				t.Errorf("err = %v, want the reader error wrapped only when the reader failed", i.Err)
+			}
+			if !bytes.Equal(i.Instruction, tt.wantBytes) {
+				t.Errorf("bytes % x, want % x", i.Instruction, tt.wantBytes)
+			}
+		})
+	}
+}
+
+/* A clean EOF ends Disassemble without an error, a failing reader does not */
+func TestDisassembleReadFailure(t *testing.T) {
+	insns, err := Disassemble(&failingReader{[]uint8{0x00}, io.EOF}, 0x0150, 0x0160)
+	if err != nil || len(insns) != 1 {
+		t.Errorf("EOF: %d instructions, err %v; want 1 and nil", len(insns), err)
+	}
+	insns, err = Disassemble(&failingReader{[]uint8{0x00}, errFlaky}, 0x0150, 0x0160)
+	if !IsReadFailure(err) || !errors.Is(err, errFlaky) || len(insns) != 2 {
+		t.Errorf("failing reader: %d instructions, err %v; want 2 and a read failure", len(insns), err)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []uint8
+		target    CPU
+		wantType  Z80AsmErrorType
+		is        func(error) bool
+		z80Prefix bool
+	}{
+		{"illegal", []uint8{0xd3}, TargetSM83, Z80AsmErrorIllegalInstruction, IsIllegal, false},
+		{"z80 prefix", []uint8{0xdd}, TargetSM83, Z80AsmErrorIllegalInstruction, IsIllegal, true},
+		{"unimplemented", []uint8{0xdd}, TargetZ80, Z80AsmErrorUnimplementedInstruction, IsUnimplemented, false},
+		{"malformed", []uint8{0xc3, 0x50}, TargetSM83, Z80AsmErrorMalformedInstruction, IsMalformed, false},
+		{"read failure", nil, TargetSM83, Z80AsmErrorReadFailure, IsReadFailure, false},
+	}
+	predicates := []func(error) bool{IsIllegal, IsUnimplemented, IsMalformed, IsReadFailure}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var i *GBInstruction
+			if tt.data == nil {
+				i, _ = DecodeInstruction(nil, 0x0150)
+			} else {
+				i, _ = DecodeInstructionFor(bytes.NewReader(tt.data), 0x0150, tt.target)
+			}
+			/* Each kind survives wrapping and is reported by exactly one predicate */
+			for _, err := range []error{i.Err, fmt.Errorf("at 0x0150: %w", i.Err)} {
+				var asmErr *Z80AsmError
+				if !errors.As(err, &asmErr) || asmErr.Type() != tt.wantType {
+					t.Errorf("%v: not a Z80AsmError of type %d", err, tt.wantType)
+				}
+				if !IsZ80AsmErrorType(err, tt.wantType) || !tt.is(err) {
+					t.Errorf("%v: not reported as type %d", err, tt.wantType)
+				}
+				matched := 0
+				for _, is := range predicates {
+					if is(err) {
+						matched++
+					}
+				}
+				if matched != 1 {
+					t.Errorf("%v: %d predicates match, want 1", err, matched)
+				}
+				if IsZ80Prefix(err) != tt.z80Prefix {
+					t.Errorf("%v: IsZ80Prefix = %v, want %v", err, !tt.z80Prefix, tt.z80Prefix)
+				}
+			}
+		})
+	}
+}
+
+/* Only the Reader methods, so the decoder cannot rely on anything else */
+type minimalReader struct {
+	buf *bytes.Buffer
+}
+
+func (m minimalReader) Read(p []byte) (int, error) { return m.buf.Read(p) }
+func (m minimalReader) ReadByte() (byte, error)    { return m.buf.ReadByte() }
+
+func TestDecodeMinimalReader(t *testing.T) {
+	program := []uint8{0x21, 0x00, 0xc0, 0xcb, 0x7e, 0x20, 0xfc, 0xc9}
+	want, err := Disassemble(bytes.NewReader(program), 0x0150, 0x0158)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Disassemble(minimalReader{bytes.NewBuffer(program)}, 0x0150, 0x0158)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || len(got) != len(want) {
+		t.Fatalf("decoded %d instructions, want 4", len(got))
+	}
+	for n := range got {
+		if got[n].ToStr() != want[n].ToStr() {
+			t.Errorf("%q, want %q", got[n].ToStr(), want[n].ToStr())
+		}
+	}
+}
+
+/* Every opcode with two operand bytes, so the buffer mixes all instruction lengths */
+func benchmarkProgram() []uint8 {
+	var program []uint8
+	for len(program) < 0x8000 {
+		for op := 0; op < 256; op++ {
+			program = append(program, uint8(op), 0x34, 0x12)
+		}
+	}
+	return program
+}
+
+func TestDecoderMatchesDecodeInstruction(t *testing.T) {
+	program := benchmarkProgram()
+	want, err := Disassemble(bytes.NewReader(program), 0x0000, uint32(len(program)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(bytes.NewReader(program), 0x0000)
+	var i GBInstruction
+	n := 0
+	for ; d.DecodeInto(&i); n++ {
+		if n >= len(want) {
+			t.Fatalf("Decoder decoded more than %d instructions", len(want))
+		}
+		if i.ToStr() != want[n].ToStr() || i.Flow != want[n].Flow || i.Cycles != want[n].Cycles {
+			t.Fatalf("instruction %d: %q, want %q", n, i.ToStr(), want[n].ToStr())
+		}
+	}
+	if n != len(want) {
+		t.Errorf("Decoder decoded %d instructions, want %d", n, len(want))
+	}
+	if d.Addr() != uint32(len(program)) {
+		t.Errorf("Addr = 0x%04x after the end, want 0x%04x", d.Addr(), len(program))
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	d := NewDecoderFor(bytes.NewReader([]uint8{0x08}), 0x0150, TargetZ80)
+	var i GBInstruction
+	d.DecodeInto(&i)
+	d.Reset(bytes.NewReader([]uint8{0xd9}), 0x0200)
+	if !d.DecodeInto(&i) || i.Addr != 0x0200 || i.Op() != "exx" {
+		t.Errorf("after Reset decoded %s, want exx at 0x0200 on the Z80", &i)
+	}
+}
+
+func BenchmarkDecodeInstruction(b *testing.B) {
+	program := benchmarkProgram()
+	b.SetBytes(int64(len(program)))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		r := bytes.NewReader(program)
+		for i, addr := DecodeInstruction(r, 0); i != nil; i, addr = DecodeInstruction(r, addr) {
+		}
+	}
+}
+
+func BenchmarkDecoderDecodeInto(b *testing.B) {
+	program := benchmarkProgram()
+	b.SetBytes(int64(len(program)))
+	b.ReportAllocs()
+	var i GBInstruction
+	d := NewDecoder(nil, 0)
+	for n := 0; n < b.N; n++ {
+		d.Reset(bytes.NewReader(program), 0)
+		for d.DecodeInto(&i) {
+		}
+	}
+}
+
+/* A malformed instruction keeps the bytes it did read, so they can still be listed */
+func TestDecodeTruncatedImmediates(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+	}{
+		{"ld bc, nn", []uint8{0x01, 0x34}},
+		{"jp nn", []uint8{0xc3, 0x50}},
+		{"call nn", []uint8{0xcd}},
+		{"ld [nn], sp", []uint8{0x08, 0x00}},
+		{"ld a, n", []uint8{0x3e}},
+		{"jr e", []uint8{0x18}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if !IsMalformed(i.Err) {
+				t.Errorf("err = %v, want malformed", i.Err)
+			}
+			if !bytes.Equal(i.Instruction, tt.data) {
+				t.Errorf("bytes % x, want % x", i.Instruction, tt.data)
+			}
+			if want := fmt.Sprintf("0x0150: %-12x Malformed Instruction", tt.data); i.ToStr() != want {
+				t.Errorf("ToStr = %q, want %q", i.ToStr(), want)
+			}
+		})
+	}
+}
+
+/* The eleven opcodes the SM83 leaves unused */
+var unusedSM83Opcodes = []uint8{0xd3, 0xdb, 0xdd, 0xe3, 0xe4, 0xeb, 0xec, 0xed, 0xf4, 0xfc, 0xfd}
+
+func TestDecodeZ80Prefixes(t *testing.T) {
+	for _, op := range unusedSM83Opcodes {
+		i, _ := DecodeInstruction(bytes.NewReader([]uint8{op, 0x21, 0x00, 0x00}), 0x0150)
+		var asmErr *Z80AsmError
+		if !errors.As(i.Err, &asmErr) {
+			t.Fatalf("0x%02x: err = %v", op, i.Err)
+		}
+		prefix := op == 0xdd || op == 0xed || op == 0xfd
+		want := IllegalUnusedOpcode
+		if prefix {
+			want = IllegalZ80Prefix
+		}
+		if asmErr.IllegalKind() != want || IsZ80Prefix(i.Err) != prefix {
+			t.Errorf("0x%02x: kind %d IsZ80Prefix %v, want kind %d", op, asmErr.IllegalKind(), IsZ80Prefix(i.Err), want)
+		}
+		/* The prefix is not followed into the Z80 instruction it would start */
+		if i.Len() != 1 {
+			t.Errorf("0x%02x: consumed %d bytes, want 1", op, i.Len())
+		}
+	}
+}
+
+func TestDecodeTruncatedCBPrefix(t *testing.T) {
+	i, _ := DecodeInstruction(bytes.NewReader([]uint8{0xcb}), 0x0150)
+	if i == nil {
+		t.Fatal("no instruction for a lone 0xcb")
+	}
+	if !bytes.Equal(i.Instruction, []uint8{0xcb}) {
+		t.Errorf("bytes = % x, want cb", i.Instruction)
+	}
+	if !IsMalformed(i.Err) {
+		t.Errorf("err = %v, want malformed", i.Err)
+	}
+	if IsIllegal(i.Err) {
+		t.Errorf("err = %v, want malformed rather than illegal", i.Err)
+	}
+}
+
+func TestDecodeUnusedOpcodesIllegal(t *testing.T) {
+	for _, op := range unusedSM83Opcodes {
+		i, next := DecodeInstruction(bytes.NewReader([]uint8{op, 0x00, 0x00}), 0x0150)
+		if i == nil {
+			t.Fatalf("0x%02x: no instruction", op)
+		}
+		if !IsIllegal(i.Err) {
+			t.Errorf("0x%02x: err = %v, want illegal", op, i.Err)
+		}
+		if len(i.Mnemonic) != 0 {
+			t.Errorf("0x%02x: mnemonic = %q, want none", op, i.Mnemonic)
+		}
+		if next != 0x0151 {
+			t.Errorf("0x%02x: next = 0x%04x, want 0x0151", op, next)
+		}
+	}
+}
+
+/* Every other opcode decodes to a mnemonic or an error, never neither */
+func TestDecodeEveryOpcode(t *testing.T) {
+	for op := 0; op < 0x100; op++ {
+		for _, prefixed := range []bool{false, true} {
+			data := []uint8{uint8(op), 0x00, 0x00}
+			if prefixed {
+				data = []uint8{0xcb, uint8(op)}
+			}
+			i, _ := DecodeInstruction(bytes.NewReader(data), 0x0150)
+			if i == nil || len(i.Mnemonic) == 0 && i.Err == nil {
+				t.Errorf("% x: blank instruction", data)
+			}
+		}
+	}
+}
+
+/* 0xf8 decodes as ld hl, sp+e with e signed, whatever the formatting options */
+func TestDecodeLdHLSPOffset(t *testing.T) {
+	tests := []struct {
+		data     []uint8
+		operands []string
+		disp     int8
+	}{
+		{[]uint8{0xf8, 0xfe}, []string{"hl", "sp-2"}, -2},
+		{[]uint8{0xf8, 0x05}, []string{"hl", "sp+5"}, 5},
+		{[]uint8{0xf8, 0x00}, []string{"hl", "sp+0"}, 0},
+		{[]uint8{0xf8, 0x7f}, []string{"hl", "sp+127"}, 127},
+		{[]uint8{0xf8, 0x80}, []string{"hl", "sp-128"}, -128},
+	}
+	for _, tt := range tests {
+		i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+		if i.Err != nil || i.Op() != "ld" || !slices.Equal(i.Operands(), tt.operands) {
+			t.Errorf("% x: %q %q %v, want ld %q", tt.data, i.Op(), i.Operands(), i.Err, tt.operands)
+		}
+		if i.Displacement == nil || *i.Displacement != tt.disp {
+			t.Errorf("% x: Displacement %v, want %d", tt.data, i.Displacement, tt.disp)
+		}
+		if i.Cycles != 3 || i.Flags.String() != "00HC" {
+			t.Errorf("% x: %d cycles, flags %v, want 3 and 00HC", tt.data, i.Cycles, i.Flags)
+		}
+	}
+}
+
+/* 16 bit immediates are little endian: the low byte comes first */
+func TestDecodeImm16ByteOrder(t *testing.T) {
+	tests := []struct {
+		data     []uint8
+		operands []string
+		imm      uint16
+	}{
+		{[]uint8{0x21, 0x34, 0x12}, []string{"hl", "0x1234"}, 0x1234},
+		{[]uint8{0x31, 0xff, 0x00}, []string{"sp", "0x00ff"}, 0x00ff},
+		{[]uint8{0xea, 0x34, 0x12}, []string{"[0x1234]", "a"}, 0x1234},
+		{[]uint8{0xfa, 0x00, 0xff}, []string{"a", "[0xff00]"}, 0xff00},
+		{[]uint8{0xcd, 0xcd, 0xab}, []string{"0xabcd"}, 0xabcd},
+		{[]uint8{0x08, 0x01, 0xc0}, []string{"[0xc001]", "sp"}, 0xc001},
+	}
+	for _, tt := range tests {
+		i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+		if !slices.Equal(i.Operands(), tt.operands) {
+			t.Errorf("% x: operands %q, want %q", tt.data, i.Operands(), tt.operands)
+		}
+		if i.Imm == nil || *i.Imm != tt.imm {
+			t.Errorf("% x: Imm %v, want 0x%04x", tt.data, i.Imm, tt.imm)
+		}
+	}
+}
+
+/* A nil reader, untyped or a nil *bytes.Reader, is a read failure rather than a panic */
+func TestDecodeNilReader(t *testing.T) {
+	var nilBytes *bytes.Reader
+	tests := []struct {
+		name string
+		r    Reader
+	}{
+		{"nil", nil},
+		{"nil *bytes.Reader", nilBytes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, target := range []CPU{TargetSM83, TargetZ80} {
+				i, next := DecodeInstructionFor(tt.r, 0x0150, target)
+				if i == nil || !IsReadFailure(i.Err) || i.Len() != 0 || next != 0x0150 {
+					t.Errorf("target %d: got %v, next 0x%04x, want an empty read failure at 0x0150", target, i, next)
+				}
+			}
+			insns, err := Disassemble(tt.r, 0x0150, 0x0160)
+			if !IsReadFailure(err) || len(insns) != 1 {
+				t.Errorf("Disassemble: %d instructions, err = %v, want the one read failure", len(insns), err)
+			}
+			var i GBInstruction
+			if d := NewDecoder(tt.r, 0x0150); !d.DecodeInto(&i) || !IsReadFailure(i.Err) {
+				t.Errorf("Decoder: err = %v, want a read failure", i.Err)
+			}
+		})
+	}
+}
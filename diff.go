@@ -0,0 +1,59 @@
+package gobjdump
+
+import "bytes"
+
+type DiffKind uint8
+
+const (
+	/* Only b has an instruction at Addr */
+	DiffAdded DiffKind = iota
+	/* Only a has an instruction at Addr */
+	DiffRemoved
+	/* Both have an instruction at Addr, with different bytes */
+	DiffChanged
+)
+
+var diffKindNames = [...]string{
+	DiffAdded:   "added",
+	DiffRemoved: "removed",
+	DiffChanged: "changed",
+}
+
+func (k DiffKind) String() string {
+	if int(k) < len(diffKindNames) {
+		return diffKindNames[k]
+	}
+	return "unknown"
+}
+
+type DiffEntry struct {
+	Kind DiffKind
+	Addr uint32
+	/* The instruction in a and in b; nil on the side that has none */
+	A, B *GBInstruction
+}
+
+/*
+ * Aligns a and b, both in ascending address order, by address and returns
+ * how b differs from a. Instructions at the same address with the same
+ * bytes are not reported.
+ */
+func DiffDisassembly(a, b []*GBInstruction) []DiffEntry {
+	var diff []DiffEntry
+	for len(a) > 0 || len(b) > 0 {
+		switch {
+		case len(b) == 0 || len(a) > 0 && a[0].Addr < b[0].Addr:
+			diff = append(diff, DiffEntry{Kind: DiffRemoved, Addr: a[0].Addr, A: a[0]})
+			a = a[1:]
+		case len(a) == 0 || b[0].Addr < a[0].Addr:
+			diff = append(diff, DiffEntry{Kind: DiffAdded, Addr: b[0].Addr, B: b[0]})
+			b = b[1:]
+		default:
+			if !bytes.Equal(a[0].Instruction, b[0].Instruction) {
+				diff = append(diff, DiffEntry{Kind: DiffChanged, Addr: a[0].Addr, A: a[0], B: b[0]})
+			}
+			a, b = a[1:], b[1:]
+		}
+	}
+	return diff
+}
@@ -0,0 +1,52 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiffDisassembly(t *testing.T) {
+	base := []uint8{0x3e, 0x12, 0x00, 0xc9}
+	tests := []struct {
+		name string
+		b    []uint8
+		want []DiffEntry
+	}{
+		{"same", []uint8{0x3e, 0x12, 0x00, 0xc9}, nil},
+		{"changed immediate", []uint8{0x3e, 0x34, 0x00, 0xc9}, []DiffEntry{{Kind: DiffChanged, Addr: 0x0150}}},
+		{"changed opcode", []uint8{0x3e, 0x12, 0x3c, 0xc9}, []DiffEntry{{Kind: DiffChanged, Addr: 0x0152}}},
+		{"appended", []uint8{0x3e, 0x12, 0x00, 0xc9, 0x00}, []DiffEntry{{Kind: DiffAdded, Addr: 0x0154}}},
+		{"truncated", []uint8{0x3e, 0x12, 0x00}, []DiffEntry{{Kind: DiffRemoved, Addr: 0x0153}}},
+		/* A longer instruction at 0x0152 swallows the ret, which is reported as removed */
+		{"realigned", []uint8{0x3e, 0x12, 0x01, 0x00, 0x00}, []DiffEntry{
+			{Kind: DiffChanged, Addr: 0x0152},
+			{Kind: DiffRemoved, Addr: 0x0153},
+		}},
+	}
+	a, _ := Disassemble(bytes.NewReader(base), 0x0150, 0x0150+uint32(len(base)))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := Disassemble(bytes.NewReader(tt.b), 0x0150, 0x0150+uint32(len(tt.b)))
+			diff := DiffDisassembly(a, b)
+			if len(diff) != len(tt.want) {
+				t.Fatalf("got %d entries %v, want %d", len(diff), diff, len(tt.want))
+			}
+			for n, e := range diff {
+				if e.Kind != tt.want[n].Kind || e.Addr != tt.want[n].Addr {
+					t.Errorf("entry %d: got %v at 0x%04x, want %v at 0x%04x", n, e.Kind, e.Addr, tt.want[n].Kind, tt.want[n].Addr)
+				}
+				if (e.A == nil) != (e.Kind == DiffAdded) || (e.B == nil) != (e.Kind == DiffRemoved) {
+					t.Errorf("entry %d: %v with A %v, B %v", n, e.Kind, e.A, e.B)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffKindString(t *testing.T) {
+	for k, want := range map[DiffKind]string{DiffAdded: "added", DiffRemoved: "removed", DiffChanged: "changed", 9: "unknown"} {
+		if got := k.String(); got != want {
+			t.Errorf("%d: got %q, want %q", k, got, want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package gobjdump
+
+import "fmt"
+
+/*
+ * Attaches Comment to every instruction whose opcode is Op and whose operands
+ * match Operands. A nil Operands matches any operand list, and a "*" token
+ * matches any single operand.
+ */
+type CommentRule struct {
+	Op       string
+	Operands []string
+	Comment  string
+}
+
+func (rule *CommentRule) Matches(i *GBInstruction) bool {
+	if rule.Op == "" || i.Op() != rule.Op {
+		return false
+	}
+	if rule.Operands == nil {
+		return true
+	}
+	operands := i.Operands()
+	if len(operands) != len(rule.Operands) {
+		return false
+	}
+	for n, pattern := range rule.Operands {
+		if pattern != "*" && pattern != operands[n] {
+			return false
+		}
+	}
+	return true
+}
+
+/* Builds the rule for writes of a to an I/O register; ldh and ld [nn] both print it as [0xffNN] */
+func mmioWriteRule(addr uint16, comment string) CommentRule {
+	return CommentRule{Op: "ld", Operands: []string{fmt.Sprintf("[0x%04x]", addr), "a"}, Comment: comment}
+}
+
+/* Built-in rules for the common MMIO register writes */
+var MMIOCommentRules = func() []CommentRule {
+	var rules []CommentRule
+	for _, reg := range []struct {
+		addr    uint16
+		comment string
+	}{
+		{0xff00, "select joypad lines"},
+		{0xff0f, "set interrupt flags"},
+		{0xff26, "sound on/off"},
+		{0xff40, "write LCDC"},
+		{0xff41, "write STAT"},
+		{0xff42, "set SCY"},
+		{0xff43, "set SCX"},
+		{0xff45, "set LYC"},
+		{0xff46, "start OAM DMA"},
+		{0xff47, "set BG palette"},
+		{0xff48, "set OBJ palette 0"},
+		{0xff49, "set OBJ palette 1"},
+		{0xff4a, "set WY"},
+		{0xff4b, "set WX"},
+		{0xffff, "set interrupt enable"},
+	} {
+		rules = append(rules, mmioWriteRule(reg.addr, reg.comment))
+	}
+	return rules
+}()
+
+/*
+ * Renders an rst as a macro invocation. The Args bytes following the rst are
+ * inline parameters consumed by the handler, and become the macro arguments.
+ */
+type RSTMacro struct {
+	Name string
+	Args int
+}
+
+type Disassembler struct {
+	/* Evaluated in order while formatting; every matching rule contributes its comment */
+	Rules []CommentRule
+
+	Options FormatOptions
+
+	/* Keyed by rst vector (0x00, 0x08, ..., 0x38) */
+	RSTMacros map[uint8]RSTMacro
+
+	/*
+	 * Attach SuspiciousWarnings to decoded instructions as comments, and in
+	 * Disassemble UnloadedHLWarning, see UnloadedHLAccesses
+	 */
+	Warnings bool
+
+	/*
+	 * When positive, Disassemble takes a run of at least this many consecutive
+	 * illegal opcodes to be data, and renders each of its bytes as a db
+	 * directive instead of an error.
+	 */
+	DataRun int
+
+	/* Never read past end in Disassemble; an instruction crossing it is malformed */
+	Bounded bool
+
+	/* Warn in Disassemble about every halt not followed by a nop, see HaltBugWarning */
+	HaltBug bool
+
+	/* The instruction set to decode, TargetSM83 by default */
+	Target CPU
+}
+
+/* Decodes one instruction like DecodeInstructionFor d.Target, expanding rst macros and attaching warnings */
+func (d *Disassembler) Decode(r Reader, addr uint32) (*GBInstruction, uint32) {
+	gbInstruction, next := d.decodeMacro(r, addr)
+	if gbInstruction != nil && d.Warnings {
+		gbInstruction.Comments = append(gbInstruction.Comments, SuspiciousWarnings(gbInstruction)...)
+	}
+	return gbInstruction, next
+}
+
+func (d *Disassembler) decodeMacro(r Reader, addr uint32) (*GBInstruction, uint32) {
+	gbInstruction, next := DecodeInstructionFor(r, addr, d.Target)
+	if gbInstruction == nil || gbInstruction.Err != nil || gbInstruction.Instruction[0]&0xc7 != 0xc7 {
+		return gbInstruction, next
+	}
+	macro, ok := d.RSTMacros[gbInstruction.Instruction[0]&0x38]
+	if !ok {
+		return gbInstruction, next
+	}
+
+	mnemonic := []string{macro.Name}
+	for n := 0; n < macro.Args; n++ {
+		operand, err := imm8(r, &gbInstruction.Instruction)
+		if err != nil {
+			gbInstruction.Err = err
+			break
+		}
+		mnemonic = append(mnemonic, operand)
+	}
+	gbInstruction.Mnemonic = mnemonic
+	/* The vector is no longer an operand, so nothing may be substituted for it */
+	gbInstruction.ResolvedTarget = nil
+	return gbInstruction, addr + uint32(len(gbInstruction.Instruction))
+}
+
+/* Decodes [start, end) with Decode, stopping early like DisassemblerLoop */
+func (d *Disassembler) Disassemble(r Reader, start uint32, end uint32) ([]*GBInstruction, error) {
+	if d.Bounded && end > start {
+		r = LimitReader(r, int64(end-start))
+	}
+	gbInstructions, err := decodeRange(r, start, end, d.Decode)
+	if d.DataRun > 0 {
+		markDataRuns(gbInstructions, d.DataRun)
+	}
+	if d.HaltBug {
+		for _, i := range gbInstructions {
+			if warning, ok := HaltBugWarning(i); ok {
+				i.Comments = append(i.Comments, warning)
+			}
+		}
+	}
+	if d.Warnings {
+		for _, i := range UnloadedHLAccesses(gbInstructions, d.Options.Labels, d.Options.Bank) {
+			i.Comments = append(i.Comments, UnloadedHLWarning)
+		}
+	}
+	return gbInstructions, err
+}
+
+/*
+ * Turns every run of at least n consecutive illegal opcodes in insns into db
+ * directives, one operand per byte so a two byte Z80 illegal keeps both
+ */
+func markDataRuns(insns []*GBInstruction, n int) {
+	run := 0
+	for k := 0; k <= len(insns); k++ {
+		if k < len(insns) && IsIllegal(insns[k].Err) {
+			run++
+			continue
+		}
+		if run >= n {
+			for _, i := range insns[k-run : k] {
+				i.Mnemonic = []string{"db"}
+				for _, b := range i.Instruction {
+					i.Mnemonic = append(i.Mnemonic, fmt.Sprintf("0x%02x", b))
+				}
+				i.Err = nil
+			}
+		}
+		run = 0
+	}
+}
+
+/* Returns the comments of every rule matching i, in rule order */
+func (d *Disassembler) Comments(i *GBInstruction) []string {
+	var comments []string
+	for n := range d.Rules {
+		if d.Rules[n].Matches(i) {
+			comments = append(comments, d.Rules[n].Comment)
+		}
+	}
+	return comments
+}
+
+/* Formats i under d.Options, followed by its own comments and those of the matching rules */
+func (d *Disassembler) Format(i *GBInstruction) string {
+	comments := append(i.Comments[:len(i.Comments):len(i.Comments)], d.Comments(i)...)
+	return i.formatLine(&d.Options, comments)
+}
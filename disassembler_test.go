@@ -0,0 +1,225 @@
+package gobjdump
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommentRuleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule CommentRule
+		data []uint8
+		want bool
+	}{
+		{"any operands", CommentRule{Op: "ld"}, []uint8{0x78}, true},
+		{"exact operands", CommentRule{Op: "ld", Operands: []string{"a", "b"}}, []uint8{0x78}, true},
+		{"wildcard", CommentRule{Op: "ld", Operands: []string{"a", "*"}}, []uint8{0x78}, true},
+		{"other operand", CommentRule{Op: "ld", Operands: []string{"a", "c"}}, []uint8{0x78}, false},
+		{"operand count", CommentRule{Op: "ld", Operands: []string{"a"}}, []uint8{0x78}, false},
+		{"other opcode", CommentRule{Op: "call"}, []uint8{0x78}, false},
+		{"empty op", CommentRule{}, []uint8{0x78}, false},
+		{"no operands", CommentRule{Op: "nop", Operands: []string{}}, []uint8{0x00}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if got := tt.rule.Matches(i); got != tt.want {
+				t.Errorf("Matches(%s) = %v, want %v", i, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisassemblerComments(t *testing.T) {
+	d := &Disassembler{Rules: append([]CommentRule{
+		{Op: "ld", Operands: []string{"*", "a"}, Comment: "store a"},
+	}, MMIOCommentRules...)}
+	tests := []struct {
+		name string
+		data []uint8
+		want []string
+	}{
+		{"ldh LCDC", []uint8{0xe0, 0x40}, []string{"store a", "write LCDC"}},
+		{"ld [nn] IE", []uint8{0xea, 0xff, 0xff}, []string{"store a", "set interrupt enable"}},
+		{"ldh unnamed", []uint8{0xe0, 0x80}, []string{"store a"}},
+		{"ldh load", []uint8{0xf0, 0x40}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if got := d.Comments(i); !slices.Equal(got, tt.want) {
+				t.Errorf("Comments(%s) = %q, want %q", i, got, tt.want)
+			}
+			line := d.Format(i)
+			for _, comment := range tt.want {
+				if !strings.Contains(line, comment) {
+					t.Errorf("Format(%s) = %q, missing %q", i, line, comment)
+				}
+			}
+		})
+	}
+}
+
+func TestRSTMacros(t *testing.T) {
+	d := &Disassembler{RSTMacros: map[uint8]RSTMacro{
+		0x28: {Name: "JumpTable", Args: 2},
+		0x30: {Name: "Yield"},
+	}}
+	tests := []struct {
+		name     string
+		data     []uint8
+		want     string
+		wantNext uint32
+	}{
+		{"with args", []uint8{0xef, 0x12, 0x34}, "0x0150: ef1234       JumpTable 0x12, 0x34", 0x0153},
+		{"no args", []uint8{0xf7, 0x12}, "0x0150: f7           Yield", 0x0151},
+		{"unmapped", []uint8{0xc7, 0x12}, "0x0150: c7           rst    0x00", 0x0151},
+		{"truncated args", []uint8{0xef, 0x12}, "0x0150: ef12         Malformed Instruction", 0x0152},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, next := d.Decode(bytes.NewReader(tt.data), 0x0150)
+			if got := d.Format(i); got != tt.want {
+				t.Errorf("Format = %q, want %q", got, tt.want)
+			}
+			if next != tt.wantNext {
+				t.Errorf("next = 0x%04x, want 0x%04x", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+/* A label or bank for the vector must not replace a macro argument */
+func TestRSTMacroArgsKeepLabels(t *testing.T) {
+	d := &Disassembler{RSTMacros: map[uint8]RSTMacro{0x28: {Name: "JumpTable", Args: 1}}}
+	tests := []struct {
+		name string
+		opts FormatOptions
+	}{
+		{"labels", FormatOptions{Labels: map[uint32]string{0x0028: "RST28"}}},
+		{"show bank", FormatOptions{ShowBank: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := d.Decode(bytes.NewReader([]uint8{0xef, 0x42}), 0x0150)
+			if got := i.ToStrWithOptions(tt.opts); !strings.HasSuffix(got, "JumpTable 0x42") {
+				t.Errorf("got %q, want the argument 0x42", got)
+			}
+		})
+	}
+}
+
+func TestDataRun(t *testing.T) {
+	data := []uint8{0x78, 0xdd, 0xdd, 0xdd, 0xdd, 0x3c, 0xd3, 0xdd, 0x3d}
+	tests := []struct {
+		name    string
+		dataRun int
+		want    []string
+	}{
+		{"off", 0, []string{
+			"0x0150: 78           ld     a, b",
+			"0x0151: dd           Illegal Instruction",
+			"0x0152: dd           Illegal Instruction",
+			"0x0153: dd           Illegal Instruction",
+			"0x0154: dd           Illegal Instruction",
+			"0x0155: 3c           inc    a",
+			"0x0156: d3           Illegal Instruction",
+			"0x0157: dd           Illegal Instruction",
+			"0x0158: 3d           dec    a",
+		}},
+		{"runs of three", 3, []string{
+			"0x0150: 78           ld     a, b",
+			"0x0151: dd           db     0xdd",
+			"0x0152: dd           db     0xdd",
+			"0x0153: dd           db     0xdd",
+			"0x0154: dd           db     0xdd",
+			"0x0155: 3c           inc    a",
+			"0x0156: d3           Illegal Instruction",
+			"0x0157: dd           Illegal Instruction",
+			"0x0158: 3d           dec    a",
+		}},
+		{"runs of two", 2, []string{
+			"0x0150: 78           ld     a, b",
+			"0x0151: dd           db     0xdd",
+			"0x0152: dd           db     0xdd",
+			"0x0153: dd           db     0xdd",
+			"0x0154: dd           db     0xdd",
+			"0x0155: 3c           inc    a",
+			"0x0156: d3           db     0xd3",
+			"0x0157: dd           db     0xdd",
+			"0x0158: 3d           dec    a",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Disassembler{DataRun: tt.dataRun}
+			insns, err := d.Disassemble(bytes.NewReader(data), 0x0150, 0x0159)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, i := range insns {
+				got = append(got, d.Format(i))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+/* The unassigned ed xx opcodes are two bytes long, and both stay in the db */
+func TestDataRunZ80(t *testing.T) {
+	d := &Disassembler{Target: TargetZ80, DataRun: 2}
+	insns, err := d.Disassemble(bytes.NewReader([]uint8{0xed, 0x00, 0xed, 0x01}), 0x0150, 0x0154)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, i := range insns {
+		got = append(got, d.Format(i))
+	}
+	want := []string{
+		"0x0150: ed00         db     0xed, 0x00",
+		"0x0152: ed01         db     0xed, 0x01",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDisassembleBounded(t *testing.T) {
+	/* nop; jp 0x0150 straddling the end at 0x0153 */
+	data := []uint8{0x00, 0xc3, 0x50, 0x01}
+	for _, bounded := range []bool{false, true} {
+		r := bytes.NewReader(data)
+		d := &Disassembler{Bounded: bounded}
+		insns, err := d.Disassemble(r, 0x0150, 0x0153)
+		last := insns[len(insns)-1]
+		if bounded {
+			if !IsMalformed(err) || !bytes.Equal(last.Instruction, []uint8{0xc3, 0x50}) {
+				t.Errorf("bounded: last %s, err %v; want jp cut short at the end", last, err)
+			}
+			if r.Len() != 1 {
+				t.Errorf("bounded: read %d bytes, want no more than 3", len(data)-r.Len())
+			}
+		} else if err != nil || last.ToStr() != "0x0151: c35001       jp     0x0150" {
+			t.Errorf("unbounded: last %s, err %v; want the whole jp", last, err)
+		}
+	}
+}
+
+func TestLimitReader(t *testing.T) {
+	r := LimitReader(bytes.NewReader([]uint8{0x01, 0x02, 0x03}), 2)
+	buf := make([]uint8, 3)
+	if n, _ := r.Read(buf); n != 2 {
+		t.Errorf("Read %d bytes, want 2", n)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("ReadByte past the limit: err = %v, want io.EOF", err)
+	}
+}
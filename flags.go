@@ -0,0 +1,107 @@
+package gobjdump
+
+/* What an instruction does to one flag */
+type FlagEffect uint8
+
+const (
+	FlagUnchanged FlagEffect = iota
+	FlagSet
+	FlagReset
+	/* Set or reset according to the result */
+	FlagAffected
+)
+
+/* The effect of an instruction on each of the SM83 flags */
+type FlagEffects struct {
+	Z, N, H, C FlagEffect
+}
+
+/* Returns f in the "Z N H C" notation of the Pan Docs, e.g. "Z0HC" or "-001" */
+func (f FlagEffects) String() string {
+	b := make([]byte, 4)
+	for n, effect := range []FlagEffect{f.Z, f.N, f.H, f.C} {
+		switch effect {
+		case FlagUnchanged:
+			b[n] = '-'
+		case FlagSet:
+			b[n] = '1'
+		case FlagReset:
+			b[n] = '0'
+		default:
+			b[n] = "ZNHC"[n]
+		}
+	}
+	return string(b)
+}
+
+/* Parses the Pan Docs notation String produces */
+func flagSpec(spec string) FlagEffects {
+	var effects [4]FlagEffect
+	for n := range effects {
+		switch spec[n] {
+		case '-':
+			effects[n] = FlagUnchanged
+		case '1':
+			effects[n] = FlagSet
+		case '0':
+			effects[n] = FlagReset
+		default:
+			effects[n] = FlagAffected
+		}
+	}
+	return FlagEffects{Z: effects[0], N: effects[1], H: effects[2], C: effects[3]}
+}
+
+/* Indexed like ALU: add, adc, sub, sbc, and, xor, or, cp */
+var aluFlags = [8]string{"Z0HC", "Z0HC", "Z1HC", "Z1HC", "Z010", "Z000", "Z000", "Z1HC"}
+
+/* Returns the flag effects of a successfully decoded SM83 instruction */
+func flagEffects(i *GBInstruction) FlagEffects {
+	op := i.Instruction[0]
+	switch {
+	case op == 0xcb:
+		cb := i.Instruction[1]
+		switch {
+		case cb&0xf8 == 0x30:
+			/* swap */
+			return flagSpec("Z000")
+		case cb < 0x40:
+			return flagSpec("Z00C")
+		case cb < 0x80:
+			/* bit */
+			return flagSpec("Z01-")
+		}
+	case op&0xc7 == 0x04:
+		/* inc r */
+		return flagSpec("Z0H-")
+	case op&0xc7 == 0x05:
+		/* dec r */
+		return flagSpec("Z1H-")
+	case op&0xcf == 0x09:
+		/* add hl, rr */
+		return flagSpec("-0HC")
+	case op == 0x07, op == 0x0f, op == 0x17, op == 0x1f:
+		return flagSpec("000C")
+	case op == 0x27:
+		/* daa */
+		return flagSpec("Z-0C")
+	case op == 0x2f:
+		/* cpl */
+		return flagSpec("-11-")
+	case op == 0x37:
+		/* scf */
+		return flagSpec("-001")
+	case op == 0x3f:
+		/* ccf */
+		return flagSpec("-00C")
+	case op >= 0x80 && op < 0xc0, op&0xc7 == 0xc6:
+		return flagSpec(aluFlags[(op>>3)&0x07])
+	case op == 0xe8, op == 0xf8:
+		/* add sp, e and ld hl, sp+e */
+		return flagSpec("00HC")
+	case op == 0xf1:
+		/* pop af */
+		return flagSpec("ZNHC")
+	}
+	return FlagEffects{}
+}
@@ -0,0 +1,63 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFlagEffects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		want FlagEffects
+	}{
+		{"scf", []uint8{0x37}, FlagEffects{Z: FlagUnchanged, N: FlagReset, H: FlagReset, C: FlagSet}},
+		{"ccf", []uint8{0x3f}, FlagEffects{N: FlagReset, H: FlagReset, C: FlagAffected}},
+		{"xor a", []uint8{0xaf}, FlagEffects{Z: FlagAffected, N: FlagReset, H: FlagReset, C: FlagReset}},
+		{"and n", []uint8{0xe6, 0x0f}, FlagEffects{Z: FlagAffected, N: FlagReset, H: FlagSet, C: FlagReset}},
+		{"cp n", []uint8{0xfe, 0x0f}, FlagEffects{Z: FlagAffected, N: FlagSet, H: FlagAffected, C: FlagAffected}},
+		{"inc b", []uint8{0x04}, FlagEffects{Z: FlagAffected, N: FlagReset, H: FlagAffected}},
+		{"add hl, de", []uint8{0x19}, FlagEffects{N: FlagReset, H: FlagAffected, C: FlagAffected}},
+		{"swap a", []uint8{0xcb, 0x37}, FlagEffects{Z: FlagAffected, N: FlagReset, H: FlagReset, C: FlagReset}},
+		{"bit 7, h", []uint8{0xcb, 0x7c}, FlagEffects{Z: FlagAffected, N: FlagReset, H: FlagSet}},
+		{"set 0, a", []uint8{0xcb, 0xc7}, FlagEffects{}},
+		{"ld a, b", []uint8{0x78}, FlagEffects{}},
+		{"pop af", []uint8{0xf1}, FlagEffects{Z: FlagAffected, N: FlagAffected, H: FlagAffected, C: FlagAffected}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if i.Flags != tt.want {
+				t.Errorf("got %v, want %v", i.Flags, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagEffectsString(t *testing.T) {
+	tests := []struct {
+		data []uint8
+		want string
+	}{
+		{[]uint8{0x37}, "-001"},
+		{[]uint8{0xaf}, "Z000"},
+		{[]uint8{0x2f}, "-11-"},
+		{[]uint8{0x00}, "----"},
+	}
+	for _, tt := range tests {
+		i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+		if got := i.Flags.String(); got != tt.want {
+			t.Errorf("% x: got %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+/* Flag effects are SM83 only, and left unset on a failed decode */
+func TestFlagEffectsUnset(t *testing.T) {
+	if i, _ := DecodeInstructionFor(bytes.NewReader([]uint8{0x37}), 0x0150, TargetZ80); i.Flags != (FlagEffects{}) {
+		t.Errorf("Z80 scf: got %v, want no effects", i.Flags)
+	}
+	if i, _ := DecodeInstruction(bytes.NewReader([]uint8{0xe6}), 0x0150); i.Err == nil || i.Flags != (FlagEffects{}) {
+		t.Errorf("truncated and: err %v, flags %v", i.Err, i.Flags)
+	}
+}
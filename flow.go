@@ -0,0 +1,69 @@
+package gobjdump
+
+/* How control leaves an instruction */
+type FlowType uint8
+
+const (
+	/* Falls through to the next instruction */
+	FlowSequential FlowType = iota
+	/* Conditional jr, jp, ret or djnz: either falls through or transfers control */
+	FlowBranch
+	/* Unconditional jr or jp nn */
+	FlowJump
+	/* call, call cc or rst; execution resumes at the next instruction on return */
+	FlowCall
+	/* ret or reti, or the Z80's retn */
+	FlowReturn
+	/* halt or stop, which resume at the next instruction on an interrupt or button press */
+	FlowHalt
+	/* jp hl, whose destination is only known at run time */
+	FlowIndirectJump
+)
+
+var flowTypeNames = [...]string{
+	FlowSequential:   "sequential",
+	FlowBranch:       "branch",
+	FlowJump:         "jump",
+	FlowCall:         "call",
+	FlowReturn:       "return",
+	FlowHalt:         "halt",
+	FlowIndirectJump: "indirect jump",
+}
+
+func (f FlowType) String() string {
+	if int(f) < len(flowTypeNames) {
+		return flowTypeNames[f]
+	}
+	return "unknown"
+}
+
+/* Reports whether control can reach the next instruction after one of flow type f */
+func (f FlowType) FallsThrough() bool {
+	return f != FlowJump && f != FlowReturn && f != FlowIndirectJump
+}
+
+/* Classifies how control leaves i; instructions that failed to decode are sequential */
+func flowType(i *GBInstruction) FlowType {
+	if i.Err != nil || len(i.Instruction) == 0 {
+		return FlowSequential
+	}
+	op := i.Instruction[0]
+	switch {
+	case op == 0x18, op == 0xc3:
+		return FlowJump
+	case op == 0xe9:
+		return FlowIndirectJump
+	case op&0xe7 == 0x20, op == 0x10 && i.Mnemonic[0] == "djnz",
+		op&0xc7 == 0xc2 && i.Mnemonic[0] == "jp", op&0xc7 == 0xc0 && i.Mnemonic[0] == "ret":
+		/* The Z80's parity and sign conditions share their opcodes with SM83 loads */
+		return FlowBranch
+	case op == 0xcd, op&0xc7 == 0xc4, op&0xc7 == 0xc7:
+		return FlowCall
+	case op == 0xc9, op == 0xd9 && i.Mnemonic[0] == "reti", op == 0xed && len(i.Instruction) > 1 && i.Instruction[1]&0xc7 == 0x45:
+		/* ret, reti, and the Z80's retn and reti */
+		return FlowReturn
+	case op == 0x76, op == 0x10:
+		return FlowHalt
+	}
+	return FlowSequential
+}
@@ -0,0 +1,117 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestResolvedTarget(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		addr uint32
+		want uint32
+		ok   bool
+	}{
+		{"backward jr", []uint8{0x18, 0xfe}, 0x0150, 0x0150, true},
+		{"backward jr wraps", []uint8{0x18, 0x80}, 0x0010, 0xff92, true},
+		{"forward jr wraps", []uint8{0x18, 0x7f}, 0xfff0, 0x0071, true},
+		{"conditional jr", []uint8{0x20, 0x05}, 0x0150, 0x0157, true},
+		{"forward jp", []uint8{0xc3, 0x00, 0x02}, 0x0150, 0x0200, true},
+		{"call", []uint8{0xcd, 0x00, 0x02}, 0x0150, 0x0200, true},
+		{"rst", []uint8{0xef}, 0x0150, 0x0028, true},
+		{"jp hl", []uint8{0xe9}, 0x0150, 0, false},
+		{"ld", []uint8{0x3e, 0x12}, 0x0150, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), tt.addr)
+			if (i.ResolvedTarget != nil) != tt.ok {
+				t.Fatalf("ResolvedTarget %v, want set %v", i.ResolvedTarget, tt.ok)
+			}
+			if tt.ok && *i.ResolvedTarget != tt.want {
+				t.Errorf("ResolvedTarget 0x%04x, want 0x%04x", *i.ResolvedTarget, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlowType(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		want FlowType
+	}{
+		{"ld", []uint8{0x78}, FlowSequential},
+		{"illegal", []uint8{0xd3}, FlowSequential},
+		{"jr nz", []uint8{0x20, 0xfe}, FlowBranch},
+		{"jp c", []uint8{0xda, 0x50, 0x01}, FlowBranch},
+		{"ret z", []uint8{0xc8}, FlowBranch},
+		{"jr", []uint8{0x18, 0xfe}, FlowJump},
+		{"jp nn", []uint8{0xc3, 0x50, 0x01}, FlowJump},
+		{"call", []uint8{0xcd, 0x50, 0x01}, FlowCall},
+		{"call nc", []uint8{0xd4, 0x50, 0x01}, FlowCall},
+		{"rst", []uint8{0xff}, FlowCall},
+		{"ret", []uint8{0xc9}, FlowReturn},
+		{"reti", []uint8{0xd9}, FlowReturn},
+		{"halt", []uint8{0x76}, FlowHalt},
+		{"stop", []uint8{0x10, 0x00}, FlowHalt},
+		{"jp hl", []uint8{0xe9}, FlowIndirectJump},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if i.Flow != tt.want {
+				t.Errorf("Flow = %v, want %v", i.Flow, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlowTypeFallsThrough(t *testing.T) {
+	for f, want := range map[FlowType]bool{
+		FlowSequential:   true,
+		FlowBranch:       true,
+		FlowJump:         false,
+		FlowCall:         true,
+		FlowReturn:       false,
+		FlowHalt:         true,
+		FlowIndirectJump: false,
+	} {
+		if f.FallsThrough() != want {
+			t.Errorf("%v: FallsThrough = %v, want %v", f, !want, want)
+		}
+	}
+}
+
+/* jp hl leaves its block with no known successor, unlike jp nn */
+func TestIndirectJump(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []uint8
+		flow  FlowType
+		succs int
+	}{
+		{"jp hl", []uint8{0xe9, 0x00}, FlowIndirectJump, 0},
+		{"jp nn", []uint8{0xc3, 0x00, 0x02, 0x00}, FlowJump, 1},
+		{"ret", []uint8{0xc9, 0x00}, FlowReturn, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, _ := Disassemble(bytes.NewReader(tt.data), 0x0150, 0x0150+uint32(len(tt.data)))
+			if insns[0].Flow != tt.flow || insns[0].ResolvedTarget != nil && tt.flow == FlowIndirectJump {
+				t.Errorf("Flow = %v, ResolvedTarget %v", insns[0].Flow, insns[0].ResolvedTarget)
+			}
+			g, err := BuildCFG(insns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(g.Blocks) != 2 || len(g.Blocks[0].Succs) != tt.succs {
+				t.Errorf("%d blocks, the first with successors %v, want 2 blocks and %d", len(g.Blocks), g.Blocks[0].Succs, tt.succs)
+			}
+		})
+	}
+	if got := FlowIndirectJump.String(); got != "indirect jump" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,305 @@
+package gobjdump
+
+import (
+	"fmt"
+	"strings"
+)
+
+type SPOffsetSyntax uint8
+
+const (
+	/* ld hl, sp+e, as spelled by RGBDS and the decoder */
+	SPOffsetRGBDS SPOffsetSyntax = iota
+	/* ldhl sp, e */
+	SPOffsetLegacy
+)
+
+type Radix uint8
+
+const (
+	RadixHex Radix = iota
+	RadixDecimal
+	/* "0b" prefixed, or "%" under RGBDS */
+	RadixBinary
+)
+
+type FormatOptions struct {
+	/* Spelling of the 0xf8 stack-relative load */
+	SPOffset SPOffsetSyntax
+	/* Append the machine cycle cost, "taken/not taken" for conditional branches */
+	Cycles bool
+	/*
+	 * Names substituted for resolved branch targets, see GenerateLabels and
+	 * LoadSymbols. rst vectors count, so a name for 0x0028 prints
+	 * "rst 0x28" as "rst Name". Targets in the switchable window are looked up
+	 * in Bank first, see SymbolKey
+	 */
+	Labels map[uint32]string
+	/* Print opcodes, registers and conditions in uppercase; numbers and labels are untouched */
+	UppercaseMnemonics bool
+	/*
+	 * Print text RGBDS can reassemble: "$" hex, ldh for the high-RAM loads,
+	 * [hl+]/[hl-] for ldi/ldd and absolute jr targets. Implies SPOffsetRGBDS.
+	 */
+	RGBDS bool
+	/* Name I/O registers accessed through ldh and ld [nn], e.g. [rLCDC], see IORegisterNames */
+	IORegisters bool
+	/* Add a column showing the instruction bytes as ASCII, like a hex editor */
+	ASCII bool
+	/* Minimum number of hex digits in addresses; 4 if less. Wider addresses always print in full */
+	AddrDigits int
+	/*
+	 * Print addresses as "bank:addr", e.g. "01:4012", instead of "0x4012",
+	 * and branch targets the same way with the bank they land in
+	 */
+	ShowBank bool
+	Bank     uint16
+	/*
+	 * In listings, leave a blank line after every jump and return, and before
+	 * every branch target, so routines stand apart
+	 */
+	BlockSeparators bool
+	/*
+	 * Radix of 8 and 16 bit immediate data, e.g. the n of ld a, n. Addresses
+	 * and signed displacements keep their usual form
+	 */
+	ImmRadix Radix
+	/*
+	 * Print the signed operand of jr, add sp, e and ld hl, sp+e as its raw
+	 * byte, e.g. 0xfd, instead of in signed decimal
+	 */
+	HexDisplacements bool
+	/* Comments keyed by address, appended to the line of the instruction there */
+	Comments map[uint32]string
+	/*
+	 * In listings, collapse runs of at least this many identical one-byte
+	 * entries, such as 0xff padding, into a single "... 0xff x 100 ..." line.
+	 * 0 lists every entry.
+	 */
+	CollapseFill int
+}
+
+/* Returns the opcode and operand tokens of i as they should be printed under opts */
+func (i *GBInstruction) render(opts *FormatOptions) (string, []string) {
+	op := i.Mnemonic[0]
+	operands := i.Mnemonic[1:]
+	if op == "ld" && i.Instruction[0] == 0xf8 && opts.SPOffset == SPOffsetLegacy && !opts.RGBDS {
+		op = "ldhl"
+		operands = []string{"sp", fmt.Sprintf("%d", int8(i.Instruction[1]))}
+	}
+	if opts.HexDisplacements && i.Displacement != nil && len(operands) > 0 {
+		converted := make([]string, len(operands))
+		copy(converted, operands)
+		raw := fmt.Sprintf("0x%02x", uint8(*i.Displacement))
+		if last := converted[len(converted)-1]; strings.HasPrefix(last, "sp") {
+			raw = "sp+" + raw
+		}
+		converted[len(converted)-1] = raw
+		operands = converted
+	}
+	if opts.RGBDS {
+		op, operands = i.rgbds(op, operands)
+	}
+	if opts.ImmRadix != RadixHex && i.Imm != nil && len(operands) > 0 {
+		last := operands[len(operands)-1]
+		if _, ok := branchTarget(i); !ok && (strings.HasPrefix(last, "0x") || strings.HasPrefix(last, "$")) {
+			converted := make([]string, len(operands))
+			copy(converted, operands)
+			converted[len(converted)-1] = formatImm(*i.Imm, len(i.Instruction)-1, opts)
+			operands = converted
+		}
+	}
+	if opts.UppercaseMnemonics {
+		op = strings.ToUpper(op)
+		upper := make([]string, len(operands))
+		for n, operand := range operands {
+			upper[n] = upperRegisters(operand)
+		}
+		operands = upper
+	}
+	if opts.IORegisters {
+		if n, addr, ok := i.directAddress(); ok {
+			if name, ok := IORegisterNames[addr]; ok {
+				named := make([]string, len(operands))
+				copy(named, operands)
+				named[n] = "[" + name + "]"
+				operands = named
+			}
+		}
+	}
+	if opts.ShowBank && !opts.RGBDS {
+		if target, ok := branchTarget(i); ok && i.ResolvedTarget != nil && len(operands) > 0 {
+			if bank, ok := targetBank(target, opts.Bank); ok {
+				banked := make([]string, len(operands))
+				copy(banked, operands)
+				banked[len(banked)-1] = string(appendAddr(nil, target, &FormatOptions{ShowBank: true, Bank: bank}))
+				operands = banked
+			}
+		}
+	}
+	if i.ResolvedTarget != nil && len(operands) > 0 {
+		if label, ok := labelAt(opts.Labels, *i.ResolvedTarget, opts.Bank); ok {
+			labelled := make([]string, len(operands))
+			copy(labelled, operands)
+			n := i.targetOperand()
+			if strings.HasPrefix(labelled[n], "[") {
+				/* The ldh memory operand keeps its brackets */
+				label = "[" + label + "]"
+			}
+			labelled[n] = label
+			operands = labelled
+		}
+	}
+	return op, operands
+}
+
+/*
+ * Returns the ROM bank a branch from bank lands in at target: bank 0 below
+ * 0x4000, bank itself in the switchable window. Targets outside ROM, and
+ * switchable-window targets from bank 0, have no known bank.
+ */
+func targetBank(target uint32, bank uint16) (uint16, bool) {
+	switch {
+	case target < ROMBankSize:
+		return 0, true
+	case target < 2*ROMBankSize && bank > 0:
+		return bank, true
+	}
+	return 0, false
+}
+
+/* Rewrites op and operands into RGBDS syntax */
+func (i *GBInstruction) rgbds(op string, operands []string) (string, []string) {
+	/* The Z80 decodes some of these opcodes as branches and 3-byte ld [nn] */
+	sm83Load := (op == "ld" || op == "ldi" || op == "ldd") && len(i.Instruction) < 3
+	switch b := i.Instruction[0]; {
+	case !sm83Load:
+	case b == 0xe0:
+		return "ldh", []string{fmt.Sprintf("[$ff00+$%02x]", i.Instruction[1]), "a"}
+	case b == 0xf0:
+		return "ldh", []string{"a", fmt.Sprintf("[$ff00+$%02x]", i.Instruction[1])}
+	case b == 0xe2:
+		return "ld", []string{"[$ff00+c]", "a"}
+	case b == 0xf2:
+		return "ld", []string{"a", "[$ff00+c]"}
+	case b == 0x22, b == 0x2a:
+		return "ld", hlPostIndex(operands, "[hl+]")
+	case b == 0x32, b == 0x3a:
+		return "ld", hlPostIndex(operands, "[hl-]")
+	}
+
+	converted := make([]string, len(operands))
+	for n, operand := range operands {
+		converted[n] = strings.ReplaceAll(operand, "0x", "$")
+	}
+	for n := range converted {
+		for _, cond := range conditions {
+			if converted[n] == cond {
+				converted[n] = strings.ToLower(cond)
+			}
+		}
+	}
+	/* RGBDS reads a bare jr operand as an address, not an offset */
+	if op == "jr" && i.ResolvedTarget != nil {
+		converted[len(converted)-1] = fmt.Sprintf("$%04x", *i.ResolvedTarget)
+	}
+	return op, converted
+}
+
+/* Replaces the [hl] operand of an ldi or ldd with its post-increment or -decrement form */
+func hlPostIndex(operands []string, hl string) []string {
+	converted := make([]string, len(operands))
+	for n, operand := range operands {
+		converted[n] = operand
+		if operand == "[hl]" {
+			converted[n] = hl
+		}
+	}
+	return converted
+}
+
+func formatCycles(i *GBInstruction) string {
+	if i.CyclesTaken != 0 {
+		return fmt.Sprintf("%d/%d cycles", i.CyclesTaken, i.CyclesNotTaken)
+	}
+	return fmt.Sprintf("%d cycles", i.Cycles)
+}
+
+/* Formats an immediate of width bytes in the radix of opts */
+func formatImm(imm uint16, width int, opts *FormatOptions) string {
+	switch opts.ImmRadix {
+	case RadixDecimal:
+		return fmt.Sprintf("%d", imm)
+	case RadixBinary:
+		prefix := "0b"
+		if opts.RGBDS {
+			prefix = "%"
+		}
+		return fmt.Sprintf("%s%0*b", prefix, width*8, imm)
+	}
+	return fmt.Sprintf("0x%0*x", width*2, imm)
+}
+
+func formatSPOffset(e int8) string {
+	if e < 0 {
+		return fmt.Sprintf("sp-%d", -int(e))
+	}
+	return fmt.Sprintf("sp+%d", e)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+/* Uppercases every word in operand that does not start with a digit or follow a "$" */
+func upperRegisters(operand string) string {
+	b := []byte(operand)
+	inNumber := false
+	for n, c := range b {
+		if !isWordByte(c) {
+			continue
+		}
+		if n == 0 || !isWordByte(b[n-1]) {
+			inNumber = c >= '0' && c <= '9' || n > 0 && b[n-1] == '$'
+		}
+		if !inNumber && c >= 'a' && c <= 'z' {
+			b[n] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+/* Bytes per db line where the data has no natural width */
+const dataBytesPerLine = 8
+
+/*
+ * Renders data as db directives of up to bytesPerLine bytes each, laid out
+ * like instruction lines so they can be interleaved with a listing. The first
+ * byte is at addr; the last line holds whatever is left over.
+ */
+func FormatDataRange(data []uint8, addr uint32, bytesPerLine int) []string {
+	var lines []string
+	for _, i := range dataInstructions(data, addr, bytesPerLine) {
+		lines = append(lines, i.ToStr())
+	}
+	return lines
+}
+
+/* Returns the db directives FormatDataRange renders, as instructions */
+func dataInstructions(data []uint8, addr uint32, bytesPerLine int) []*GBInstruction {
+	if bytesPerLine < 1 {
+		bytesPerLine = 1
+	}
+	var insns []*GBInstruction
+	for len(data) > 0 {
+		n := min(bytesPerLine, len(data))
+		i := &GBInstruction{Addr: addr, Instruction: data[:n], Mnemonic: []string{"db"}}
+		for _, b := range data[:n] {
+			i.Mnemonic = append(i.Mnemonic, fmt.Sprintf("0x%02x", b))
+		}
+		insns = append(insns, i)
+		data = data[n:]
+		addr += uint32(n)
+	}
+	return insns
+}
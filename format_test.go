@@ -0,0 +1,356 @@
+package gobjdump
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFormatSPOffset(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		opts FormatOptions
+		want string
+	}{
+		{"positive", []uint8{0xf8, 0x05}, FormatOptions{}, "0x0150: f805         ld     hl, sp+5"},
+		{"negative", []uint8{0xf8, 0xfe}, FormatOptions{}, "0x0150: f8fe         ld     hl, sp-2"},
+		{"legacy positive", []uint8{0xf8, 0x05}, FormatOptions{SPOffset: SPOffsetLegacy}, "0x0150: f805         ldhl   sp, 5"},
+		{"legacy negative", []uint8{0xf8, 0xfe}, FormatOptions{SPOffset: SPOffsetLegacy}, "0x0150: f8fe         ldhl   sp, -2"},
+		{"rgbds overrides legacy", []uint8{0xf8, 0xfe}, FormatOptions{SPOffset: SPOffsetLegacy, RGBDS: true}, "0x0150: f8fe         ld     hl, sp-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if got := i.ToStrWithOptions(tt.opts); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatUppercase(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		opts FormatOptions
+		want string
+	}{
+		{"registers", []uint8{0x78}, FormatOptions{UppercaseMnemonics: true}, "0x0150: 78           LD     A, B"},
+		{"hex digits untouched", []uint8{0x3e, 0x0f}, FormatOptions{UppercaseMnemonics: true}, "0x0150: 3e0f         LD     A, 0x0f"},
+		{"memory operand", []uint8{0xcb, 0x7e}, FormatOptions{UppercaseMnemonics: true}, "0x0150: cb7e         BIT    7, [HL]"},
+		{"rgbds hex", []uint8{0x3e, 0x0f}, FormatOptions{UppercaseMnemonics: true, RGBDS: true}, "0x0150: 3e0f         LD     A, $0f"},
+		{"labels untouched", []uint8{0xc3, 0xab, 0x01}, FormatOptions{UppercaseMnemonics: true, Labels: map[uint32]string{0x01ab: "main"}},
+			"0x0150: c3ab01       JP     main"},
+		{"off", []uint8{0x78}, FormatOptions{}, "0x0150: 78           ld     a, b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if got := i.ToStrWithOptions(tt.opts); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRGBDS(t *testing.T) {
+	tests := []struct {
+		data []uint8
+		want string
+	}{
+		{[]uint8{0x3e, 0x0f}, "ld     a, $0f"},
+		{[]uint8{0x01, 0x34, 0x12}, "ld     bc, $1234"},
+		{[]uint8{0xe0, 0x44}, "ldh    [$ff00+$44], a"},
+		{[]uint8{0xf0, 0x80}, "ldh    a, [$ff00+$80]"},
+		{[]uint8{0xe2}, "ld     [$ff00+c], a"},
+		{[]uint8{0x22}, "ld     [hl+], a"},
+		{[]uint8{0x3a}, "ld     a, [hl-]"},
+		{[]uint8{0x20, 0xfe}, "jr     nz, $0150"},
+		{[]uint8{0xc3, 0x50, 0x01}, "jp     $0150"},
+		{[]uint8{0xea, 0x00, 0xc0}, "ld     [$c000], a"},
+		{[]uint8{0xf8, 0xfe}, "ld     hl, sp-2"},
+		{[]uint8{0xef}, "rst    $28"},
+		{[]uint8{0x08, 0x00, 0xc0}, "ld     [$c000], sp"},
+		{[]uint8{0xcb, 0x7e}, "bit    7, [hl]"},
+	}
+	for _, tt := range tests {
+		i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+		got := i.ToStrWithOptions(FormatOptions{RGBDS: true})
+		if want := fmt.Sprintf("0x0150: %-12x %s", tt.data, tt.want); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestFormatLDH(t *testing.T) {
+	tests := []struct {
+		data []uint8
+		opts FormatOptions
+		want string
+	}{
+		{[]uint8{0xf0, 0x44}, FormatOptions{}, "0x0150: f044         ld     a, [0xff44]"},
+		{[]uint8{0xe0, 0x80}, FormatOptions{}, "0x0150: e080         ld     [0xff80], a"},
+		{[]uint8{0xf0, 0x00}, FormatOptions{}, "0x0150: f000         ld     a, [0xff00]"},
+		{[]uint8{0xf0, 0x44}, FormatOptions{RGBDS: true}, "0x0150: f044         ldh    a, [$ff00+$44]"},
+	}
+	for _, tt := range tests {
+		i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+		if got := i.ToStrWithOptions(tt.opts); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFormatASCII(t *testing.T) {
+	tests := []struct {
+		data []uint8
+		want string
+	}{
+		{[]uint8{0x21, 0x48, 0x69}, "0x0150: 214869       !Hi ld     hl, 0x6948"},
+		{[]uint8{0x3e, 0x41}, "0x0150: 3e41         >A  ld     a, 0x41"},
+		{[]uint8{0x00}, "0x0150: 00           .   nop"},
+		{[]uint8{0x7e}, "0x0150: 7e           ~   ld     a, [hl]"},
+		{[]uint8{0xd3}, "0x0150: d3           .   Illegal Instruction"},
+	}
+	for _, tt := range tests {
+		i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+		if got := i.ToStrWithOptions(FormatOptions{ASCII: true}); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFormatAddrDigits(t *testing.T) {
+	tests := []struct {
+		addr   uint32
+		digits int
+		want   string
+	}{
+		{0x0150, 0, "0x0150: 3c           inc    a"},
+		{0x0150, 2, "0x0150: 3c           inc    a"},
+		{0x0150, 6, "0x000150: 3c           inc    a"},
+		{0x123456, 0, "0x123456: 3c           inc    a"},
+		{0x123456, 4, "0x123456: 3c           inc    a"},
+		{0x123456, 8, "0x00123456: 3c           inc    a"},
+	}
+	for _, tt := range tests {
+		i, _ := DecodeInstruction(bytes.NewReader([]uint8{0x3c}), tt.addr)
+		if got := i.ToStrWithOptions(FormatOptions{AddrDigits: tt.digits}); got != tt.want {
+			t.Errorf("0x%x, %d digits: got %q, want %q", tt.addr, tt.digits, got, tt.want)
+		}
+	}
+}
+
+/* Lines of a listing stay aligned when addresses grow past 16 bits */
+func TestListingAddrDigits(t *testing.T) {
+	insns, _ := Disassemble(bytes.NewReader([]uint8{0x3c, 0x3e, 0x12}), 0xfffff, 0x100002)
+	d := &Disassembler{Options: FormatOptions{AddrDigits: 6}}
+	var buf bytes.Buffer
+	d.WriteListing(&buf, insns)
+	want := "0x0fffff: 3c           inc    a\n" +
+		"0x100000: 3e12         ld     a, 0x12\n"
+	if buf.String() != want {
+		t.Errorf("listing:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestFormatDataRange(t *testing.T) {
+	data := []uint8{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}
+	tests := []struct {
+		name         string
+		data         []uint8
+		bytesPerLine int
+		want         []string
+	}{
+		{"three per line", data, 3, []string{
+			"0x0150: 000102       db     0x00, 0x01, 0x02",
+			"0x0153: 030405       db     0x03, 0x04, 0x05",
+			"0x0156: 060708       db     0x06, 0x07, 0x08",
+			"0x0159: 09           db     0x09",
+		}},
+		{"one line", data[:2], 8, []string{"0x0150: 0001         db     0x00, 0x01"}},
+		{"zero per line", data[:2], 0, []string{"0x0150: 00           db     0x00", "0x0151: 01           db     0x01"}},
+		{"empty", nil, 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatDataRange(tt.data, 0x0150, tt.bytesPerLine); !slices.Equal(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+/* Operands are only separated by commas between them, and no-operand lines have no trailing blanks */
+func TestFormatOperandSeparators(t *testing.T) {
+	tests := []struct {
+		data []uint8
+		want string
+	}{
+		{[]uint8{0x00}, "0x0150: 00           nop"},
+		{[]uint8{0xc3, 0x50, 0x01}, "0x0150: c35001       jp     0x0150"},
+		{[]uint8{0x78}, "0x0150: 78           ld     a, b"},
+		{[]uint8{0xcb, 0x7e}, "0x0150: cb7e         bit    7, [hl]"},
+		{[]uint8{0xc9}, "0x0150: c9           ret"},
+	}
+	for _, tt := range tests {
+		i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+		if got := i.ToStr(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+/* Under ShowBank, branch targets carry the bank they land in: bank 0 below 0x4000, the current bank in 0x4000-0x7fff, none elsewhere */
+func TestFormatBankedTargets(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		bank uint16
+		want string
+	}{
+		{"call into bank 0", []uint8{0xcd, 0x50, 0x01}, 1, "01:4000: cd5001       call   00:0150"},
+		{"call within bank", []uint8{0xcd, 0x10, 0x40}, 3, "03:4000: cd1040       call   03:4010"},
+		{"jr within bank", []uint8{0x18, 0xfe}, 1, "01:4000: 18fe         jr     01:4000"},
+		{"wide bank", []uint8{0xcd, 0x10, 0x40}, 0x1ff, "01ff:4000: cd1040       call   01ff:4010"},
+		{"call into wram", []uint8{0xcd, 0x00, 0xc0}, 1, "01:4000: cd00c0       call   0xc000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x4000)
+			if got := i.ToStrWithOptions(FormatOptions{ShowBank: true, Bank: tt.bank}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+/* ImmRadix applies to immediate data only, never to addresses or displacements */
+func TestFormatImmRadix(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []uint8
+		radix Radix
+		rgbds bool
+		want  string
+	}{
+		{"decimal byte", []uint8{0x3e, 0x0f}, RadixDecimal, false, "ld     a, 15"},
+		{"decimal word", []uint8{0x21, 0x34, 0x12}, RadixDecimal, false, "ld     hl, 4660"},
+		{"binary byte", []uint8{0x3e, 0x0f}, RadixBinary, false, "ld     a, 0b00001111"},
+		{"binary word", []uint8{0x21, 0x34, 0x12}, RadixBinary, false, "ld     hl, 0b0001001000110100"},
+		{"rgbds binary", []uint8{0x36, 0x05}, RadixBinary, true, "ld     [hl], %00000101"},
+		{"rgbds decimal", []uint8{0x3e, 0x0f}, RadixDecimal, true, "ld     a, 15"},
+		{"hex", []uint8{0x3e, 0x0f}, RadixHex, false, "ld     a, 0x0f"},
+		{"jump target", []uint8{0xc3, 0x50, 0x01}, RadixDecimal, false, "jp     0x0150"},
+		{"address", []uint8{0xea, 0x00, 0xc0}, RadixDecimal, false, "ld     [0xc000], a"},
+		{"displacement", []uint8{0xe8, 0xfd}, RadixBinary, false, "add    sp, -3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			got := i.ToStrWithOptions(FormatOptions{ImmRadix: tt.radix, RGBDS: tt.rgbds})
+			if !strings.HasSuffix(got, tt.want) {
+				t.Errorf("got %q, want it to end in %q", got, tt.want)
+			}
+		})
+	}
+}
+
+/* HexDisplacements prints signed operands as their raw byte, and leaves everything else alone */
+func TestFormatHexDisplacements(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		hex  bool
+		want string
+	}{
+		{"add sp signed", []uint8{0xe8, 0xfd}, false, "0x0150: e8fd         add    sp, -3"},
+		{"add sp raw", []uint8{0xe8, 0xfd}, true, "0x0150: e8fd         add    sp, 0xfd"},
+		{"sp offset raw", []uint8{0xf8, 0xfe}, true, "0x0150: f8fe         ld     hl, sp+0xfe"},
+		{"jr raw", []uint8{0x18, 0xfe}, true, "0x0150: 18fe         jr     0xfe"},
+		{"conditional jr raw", []uint8{0x20, 0x05}, true, "0x0150: 2005         jr     NZ, 0x05"},
+		{"immediate untouched", []uint8{0x3e, 0xfd}, true, "0x0150: 3efd         ld     a, 0xfd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if got := i.ToStrWithOptions(FormatOptions{HexDisplacements: tt.hex}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+/* Comments are appended to the line at their address only, after any the instruction carries */
+func TestFormatComments(t *testing.T) {
+	program := []uint8{0x3e, 0x12, 0x3d, 0x20, 0xfd}
+	insns, _ := Disassemble(bytes.NewReader(program), 0x0150, 0x0155)
+	insns[2].Comments = []string{"warning"}
+	tests := []struct {
+		name string
+		opts FormatOptions
+		want []string
+	}{
+		{"one address", FormatOptions{Comments: map[uint32]string{0x0152: "loop"}}, []string{
+			"0x0150: 3e12         ld     a, 0x12",
+			"0x0152: 3d           dec    a ; loop",
+			"0x0153: 20fd         jr     NZ, -3 ; warning",
+		}},
+		{"after instruction comments", FormatOptions{Comments: map[uint32]string{0x0153: "until zero"}}, []string{
+			"0x0150: 3e12         ld     a, 0x12",
+			"0x0152: 3d           dec    a",
+			"0x0153: 20fd         jr     NZ, -3 ; warning; until zero",
+		}},
+		{"after cycles", FormatOptions{Cycles: true, Comments: map[uint32]string{0x0150: "counter"}}, []string{
+			"0x0150: 3e12         ld     a, 0x12 ; 2 cycles; counter",
+			"0x0152: 3d           dec    a ; 1 cycles",
+			"0x0153: 20fd         jr     NZ, -3 ; 3/2 cycles; warning",
+		}},
+		{"no instruction there", FormatOptions{Comments: map[uint32]string{0x0151: "inside ld"}}, []string{
+			"0x0150: 3e12         ld     a, 0x12",
+			"0x0152: 3d           dec    a",
+			"0x0153: 20fd         jr     NZ, -3 ; warning",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for n, i := range insns {
+				if got := i.ToStrWithOptions(tt.opts); got != tt.want[n] {
+					t.Errorf("got %q, want %q", got, tt.want[n])
+				}
+			}
+			if !slices.Equal(insns[2].Comments, []string{"warning"}) {
+				t.Errorf("instruction comments changed to %q", insns[2].Comments)
+			}
+		})
+	}
+}
+
+func TestFormatRSTSymbol(t *testing.T) {
+	symbols, err := LoadSymbols(strings.NewReader("00:0028 _WaitVBlank\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, _ := DecodeInstruction(bytes.NewReader([]uint8{0xef}), 0x0150)
+	tests := []struct {
+		name string
+		opts FormatOptions
+		want string
+	}{
+		{"no symbols", FormatOptions{}, "rst    0x28"},
+		{"legacy", FormatOptions{Labels: symbols}, "rst    _WaitVBlank"},
+		{"rgbds", FormatOptions{Labels: symbols, RGBDS: true}, "rst    _WaitVBlank"},
+		{"other vector", FormatOptions{Labels: map[uint32]string{0x0030: "Other"}}, "rst    0x28"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := i.ToStrWithOptions(tt.opts)
+			if !strings.HasSuffix(got, tt.want) {
+				t.Errorf("got %q, want suffix %q", got, tt.want)
+			}
+		})
+	}
+}
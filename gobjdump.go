@@ -3,10 +3,12 @@ package gobjdump
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"strings"
+	"iter"
+	"os"
+	"slices"
 )
 
 type Z80AsmErrorType uint8
@@ -16,10 +18,66 @@ const (
 	Z80AsmErrorUnimplementedInstruction
 	Z80AsmErrorMalformedInstruction
 	Z80AsmErrorUnknown
+	/* The reader failed before an opcode byte could be read */
+	Z80AsmErrorReadFailure
+)
+
+/* Why an opcode is a Z80AsmErrorIllegalInstruction */
+type IllegalKind uint8
+
+const (
+	/* An opcode slot the SM83 leaves unused */
+	IllegalUnusedOpcode IllegalKind = iota
+	/* A Z80 prefix byte (0xdd, 0xed, 0xfd), which the SM83 does not have */
+	IllegalZ80Prefix
 )
 
 type Z80AsmError struct {
 	errorType Z80AsmErrorType
+	/* The underlying reader error, if any */
+	err error
+	/* Only meaningful for Z80AsmErrorIllegalInstruction */
+	illegal IllegalKind
+}
+
+func (e *Z80AsmError) Unwrap() error {
+	return e.err
+}
+
+func (e *Z80AsmError) Type() Z80AsmErrorType {
+	return e.errorType
+}
+
+func (e *Z80AsmError) IllegalKind() IllegalKind {
+	return e.illegal
+}
+
+/* Reports whether err is, or wraps, a Z80AsmError of type t */
+func IsZ80AsmErrorType(err error, t Z80AsmErrorType) bool {
+	var asmErr *Z80AsmError
+	return errors.As(err, &asmErr) && asmErr.errorType == t
+}
+
+func IsIllegal(err error) bool {
+	return IsZ80AsmErrorType(err, Z80AsmErrorIllegalInstruction)
+}
+
+/* Reports whether err is, or wraps, an illegal instruction error for a Z80 prefix byte */
+func IsZ80Prefix(err error) bool {
+	var asmErr *Z80AsmError
+	return errors.As(err, &asmErr) && asmErr.errorType == Z80AsmErrorIllegalInstruction && asmErr.illegal == IllegalZ80Prefix
+}
+
+func IsUnimplemented(err error) bool {
+	return IsZ80AsmErrorType(err, Z80AsmErrorUnimplementedInstruction)
+}
+
+func IsMalformed(err error) bool {
+	return IsZ80AsmErrorType(err, Z80AsmErrorMalformedInstruction)
+}
+
+func IsReadFailure(err error) bool {
+	return IsZ80AsmErrorType(err, Z80AsmErrorReadFailure)
 }
 
 func (e *Z80AsmError) Error() string {
@@ -30,6 +88,11 @@ func (e *Z80AsmError) Error() string {
 		return "Unimplemented Instruction"
 	case Z80AsmErrorMalformedInstruction:
 		return "Malformed Instruction"
+	case Z80AsmErrorReadFailure:
+		if e.err != nil {
+			return "Read Failure: " + e.err.Error()
+		}
+		return "Read Failure"
 	default:
 		return "Unknown"
 	}
@@ -40,8 +103,41 @@ type GBInstruction struct {
 	Instruction []uint8
 	Mnemonic    []string
 	Err         error
-    Prev        *GBInstruction
-    Next        *GBInstruction
+	/*
+	 * Neighbours in a decoded slice, forming a doubly linked list in address
+	 * order; set by Disassemble and LinkInstructions, nil from the single
+	 * instruction decoders
+	 */
+	Prev *GBInstruction
+	Next *GBInstruction
+	/* Only set by AttachProvenance */
+	Provenance *Provenance
+	/* Annotations appended to the formatted line */
+	Comments []string
+	/*
+	 * Destination of a jr, djnz, jp nn, call or rst, or the high-RAM address
+	 * of an ldh; nil for everything else, including an rst expanded as an
+	 * RSTMacro
+	 */
+	ResolvedTarget *uint32
+	/* How control leaves the instruction */
+	Flow FlowType
+	/* CategoryNone if the instruction failed to decode */
+	Category Category
+	/* The unsigned 8 or 16 bit immediate, including the low byte of an ldh address; nil if none */
+	Imm *uint16
+	/* The signed operand of jr, djnz, add sp, e and ld hl, sp+e; nil for everything else */
+	Displacement *int8
+	/*
+	 * SM83 machine cycles. For conditional branches Cycles is the not-taken
+	 * cost and CyclesTaken/CyclesNotTaken hold both costs; they are 0 for
+	 * every other instruction
+	 */
+	Cycles         int
+	CyclesTaken    int
+	CyclesNotTaken int
+	/* SM83 flag effects; all FlagUnchanged for other targets and failed decodes */
+	Flags FlagEffects
 }
 
 var r8 = []string{
@@ -69,17 +165,23 @@ var r16_af = []string{
 	"af",
 }
 
+/* The SM83 has no parity or sign flag, so only the first four Z80 conditions exist */
 var conditions = []string{
 	"NZ",
 	"Z",
 	"NC",
 	"C",
-	"PO",
-	"PE",
-	"P",
-	"M",
 }
 
+/* Looks up a condition code, rejecting the Z80-only PO, PE, P and M */
+func condition(cc uint8) (string, error) {
+	if int(cc) >= len(conditions) {
+		return "", &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction}
+	}
+	return conditions[cc], nil
+}
+
+/* Indexed by bits 3-5 of a 0xcb opcode; the Z80 has sll in place of swap, see z80CBOpcodes */
 var rotateShift = []string{
 	"rlc",
 	"rrc",
@@ -102,102 +204,83 @@ var ALU = [][]string{
 	[]string{"cp"},
 }
 
-var interruptModes = []string{
-	"0",
-	"0/1",
-	"1",
-	"2",
-	"0",
-	"0/1",
-	"1",
-	"2",
-}
-
-var blockInstructions = [4][4]string{
-	[4]string{"ldi", "cpi", "ini", "outi"},
-	[4]string{"ldd", "cpd", "ind", "outd"},
-	[4]string{"ldir", "cpir", "inir", "otir"},
-	[4]string{"lddr", "cpdr", "indr", "otdr"},
+/*
+ * Consumes n immediate bytes from the stream and appends them to the args
+ * buffer. Every byte consumed is appended even when the read fails part way,
+ * so the buffer always holds exactly the bytes taken from r. Running out of
+ * input is a Z80AsmErrorMalformedInstruction; any other read error is a
+ * Z80AsmErrorUnknown wrapping it.
+ * returns: the bytes read
+ */
+func readImm(r Reader, instruction *[]uint8, n int) ([]uint8, error) {
+	start := len(*instruction)
+	for k := 0; k < n; k++ {
+		nextByte, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return nil, &Z80AsmError{errorType: Z80AsmErrorMalformedInstruction}
+			}
+			return nil, &Z80AsmError{errorType: Z80AsmErrorUnknown, err: err}
+		}
+		*instruction = append(*instruction, nextByte)
+	}
+	return (*instruction)[start:], nil
 }
 
 /* Consumes an immediate 8 bit value from the stream, updates the args buffer with it */
-func imm8(r *bytes.Reader, instruction *[]uint8) (string, error) {
-	nextByte, err := r.ReadByte()
+func imm8(r Reader, instruction *[]uint8) (string, error) {
+	imm, err := readImm(r, instruction, 1)
 	if err != nil {
-		if err == io.EOF {
-			return "", &Z80AsmError{errorType: Z80AsmErrorMalformedInstruction}
-		} else {
-			return "", &Z80AsmError{errorType: Z80AsmErrorUnknown}
-		}
+		return "", err
 	}
-	*instruction = append(*instruction, nextByte)
-	return fmt.Sprintf("0x%02x", nextByte), nil
+	return fmt.Sprintf("0x%02x", imm[0]), nil
 }
 
 /* Consumes a signed immediate 8 bit value from the stream, updates the args buffer with it */
-func imm8_s(r *bytes.Reader, instruction *[]uint8) (string, error) {
-	nextByte, err := r.ReadByte()
+func imm8_s(r Reader, instruction *[]uint8) (string, error) {
+	imm, err := readImm(r, instruction, 1)
 	if err != nil {
-		if err == io.EOF {
-			return "", &Z80AsmError{errorType: Z80AsmErrorMalformedInstruction}
-		} else {
-			return "", &Z80AsmError{errorType: Z80AsmErrorUnknown}
-		}
+		return "", err
 	}
-	*instruction = append(*instruction, nextByte)
-	return fmt.Sprintf("%d", int8(nextByte)), nil
+	return fmt.Sprintf("%d", int8(imm[0])), nil
 }
 
-func imm16(r *bytes.Reader, instruction *[]uint8) (string, error) {
-	imm := make([]uint8, 2)
-	_, err := io.ReadFull(r, imm)
+/* Consumes a little-endian immediate 16 bit value from the stream, updates the args buffer with it */
+func imm16(r Reader, instruction *[]uint8) (string, error) {
+	imm, err := readImm(r, instruction, 2)
 	if err != nil {
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
-			return "", &Z80AsmError{errorType: Z80AsmErrorMalformedInstruction}
-		} else {
-			return "", &Z80AsmError{errorType: Z80AsmErrorUnknown}
-		}
+		return "", err
 	}
-	*instruction = append(*instruction, imm[0], imm[1])
-	return fmt.Sprintf("0x%02x%02x", imm[1], imm[0]), nil
+	return fmt.Sprintf("0x%04x", binary.LittleEndian.Uint16(imm)), nil
 }
 
-func imm16_addr(r *bytes.Reader, instruction *[]uint8) (string, error) {
-	imm := make([]uint8, 2)
-	_, err := io.ReadFull(r, imm)
+/* Like imm16, formatted as a memory operand */
+func imm16_addr(r Reader, instruction *[]uint8) (string, error) {
+	imm, err := readImm(r, instruction, 2)
 	if err != nil {
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
-			return "", &Z80AsmError{errorType: Z80AsmErrorMalformedInstruction}
-		} else {
-			return "", &Z80AsmError{errorType: Z80AsmErrorUnknown}
-		}
+		return "", err
 	}
-	*instruction = append(*instruction, imm[0], imm[1])
-	return fmt.Sprintf("[0x%02x%02x]", imm[1], imm[0]), nil
+	return fmt.Sprintf("[0x%04x]", binary.LittleEndian.Uint16(imm)), nil
 }
 
-func r16_af_addr(r *bytes.Reader, instruction *[]uint8) string {
+func r16_af_addr(r Reader, instruction *[]uint8) string {
 	reg_index := ((*instruction)[0] & 0x30) >> 4
 	return fmt.Sprintf("[%s]", r16_af[reg_index])
 }
 
-func r16_sp_addr(r *bytes.Reader, instruction *[]uint8) string {
+func r16_sp_addr(r Reader, instruction *[]uint8) string {
 	reg_index := ((*instruction)[0] & 0x30) >> 4
 	return fmt.Sprintf("[%s]", r16_sp[reg_index])
 }
 
-func decodeDJNZ(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	*mnemonic = append(*mnemonic, "djnz")
-	/* Read operand (next byte) */
-	operand, err := imm8_s(r, instruction)
-	if err != nil {
-		return err
-	}
-	*mnemonic = append(*mnemonic, operand)
-	return nil
+/* stop is encoded as 0x10 0x00; the padding byte is consumed but not shown */
+func decodeSTOP(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	*mnemonic = append(*mnemonic, "stop")
+	_, err := imm8(r, instruction)
+	return err
 }
 
-func decodeJR_E(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeJR_E(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "jr")
 	/* Read operand (next byte) */
 	operand, err := imm8_s(r, instruction)
@@ -208,10 +291,14 @@ func decodeJR_E(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error
 	return nil
 }
 
-func decodeJR_cond_E(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeJR_cond_E(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "jr")
 	cond_index := ((*instruction)[0]&0x38)>>3 - 4
-	*mnemonic = append(*mnemonic, conditions[cond_index])
+	cond, err := condition(cond_index)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, cond)
 	operand, err := imm8_s(r, instruction)
 	if err != nil {
 		return err
@@ -221,7 +308,7 @@ func decodeJR_cond_E(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string)
 
 }
 
-func decodeLD_r16_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeLD_r16_nn(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	reg_index := ((*instruction)[0] & 0x30) >> 4
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, r16_sp[reg_index])
@@ -233,71 +320,64 @@ func decodeLD_r16_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string)
 	return nil
 }
 
-func decodeADD_hl_r16(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeADD_hl_r16(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	reg_index := ((*instruction)[0] & 0x30) >> 4
 	*mnemonic = append(*mnemonic, "add")
 	*mnemonic = append(*mnemonic, "hl")
 	*mnemonic = append(*mnemonic, (r16_sp[reg_index]))
 }
 
-func decodeLD_BC_A(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLD_BC_A(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, "[bc]")
 	*mnemonic = append(*mnemonic, "a")
 }
 
-func decodeLD_DE_A(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLD_DE_A(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, "[de]")
 	*mnemonic = append(*mnemonic, "a")
 }
 
-func decodeLDI_HL_A(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLDI_HL_A(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ldi")
 	*mnemonic = append(*mnemonic, "[hl]")
 	*mnemonic = append(*mnemonic, "a")
 }
 
-func decodeLDD_HL_A(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLDD_HL_A(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ldd")
 	*mnemonic = append(*mnemonic, "[hl]")
 	*mnemonic = append(*mnemonic, "a")
 }
 
-func decodeLD_nn_HL(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeLD_nn_A(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "ld")
 	operand, err := imm16_addr(r, instruction)
 	if err != nil {
 		return err
 	}
 	*mnemonic = append(*mnemonic, operand)
-	*mnemonic = append(*mnemonic, "hl")
+	*mnemonic = append(*mnemonic, "a")
 	return nil
 }
 
-func decodeLD_nn_A(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	*mnemonic = append(*mnemonic, "ld")
-	operand, err := imm16_addr(r, instruction)
-	if err != nil {
-		return err
-	}
-	*mnemonic = append(*mnemonic, operand)
-	*mnemonic = append(*mnemonic, "a")
-	return nil
+/* The 0xe0/0xf0 loads address 0xff00 + n, printed as the resolved address */
+func highRAMAddr(n uint8) string {
+	return fmt.Sprintf("[0x%04x]", 0xff00|uint16(n))
 }
 
-func decodeLD_n_A(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeLD_n_A(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "ld")
-	operand, err := imm8(r, instruction)
-	if err != nil {
+	if _, err := imm8(r, instruction); err != nil {
 		return err
 	}
-	*mnemonic = append(*mnemonic, fmt.Sprintf("[0xff00 + %s]", operand))
+	*mnemonic = append(*mnemonic, highRAMAddr((*instruction)[1]))
 	*mnemonic = append(*mnemonic, "a")
 	return nil
 }
 
-func decodeADD_SP_n(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeADD_SP_n(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "add")
 	*mnemonic = append(*mnemonic, "sp")
 	operand, err := imm8_s(r, instruction)
@@ -308,64 +388,53 @@ func decodeADD_SP_n(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) e
 	return nil
 }
 
-func decodeLD_A_n(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeLD_A_n(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, "a")
-	operand, err := imm8(r, instruction)
-	if err != nil {
+	if _, err := imm8(r, instruction); err != nil {
 		return err
 	}
-	*mnemonic = append(*mnemonic, fmt.Sprintf("[0xff00 + %s]", operand))
+	*mnemonic = append(*mnemonic, highRAMAddr((*instruction)[1]))
 	return nil
 }
 
-func decodeLD_HL_SP(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	*mnemonic = append(*mnemonic, "ldhl")
-	operand, err := imm8_s(r, instruction)
+/* ld hl, sp+e, as most assemblers spell it; SPOffsetLegacy prints ldhl sp, e */
+func decodeLD_HL_SP(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	imm, err := readImm(r, instruction, 1)
 	if err != nil {
 		return err
 	}
-	*mnemonic = append(*mnemonic, "sp")
-	*mnemonic = append(*mnemonic, operand)
+	*mnemonic = append(*mnemonic, "ld")
+	*mnemonic = append(*mnemonic, "hl")
+	*mnemonic = append(*mnemonic, formatSPOffset(int8(imm[0])))
 	return nil
 }
 
-func decodeLD_A_BC(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLD_A_BC(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, "a")
 	*mnemonic = append(*mnemonic, "[bc]")
 }
 
-func decodeLD_A_DE(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLD_A_DE(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, "a")
 	*mnemonic = append(*mnemonic, "[de]")
 }
 
-func decodeLDI_A_HL(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLDI_A_HL(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ldi")
 	*mnemonic = append(*mnemonic, "a")
 	*mnemonic = append(*mnemonic, "[hl]")
 }
 
-func decodeLDD_A_HL(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLDD_A_HL(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ldd")
 	*mnemonic = append(*mnemonic, "a")
 	*mnemonic = append(*mnemonic, "[hl]")
 }
 
-func decodeLD_HL_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	*mnemonic = append(*mnemonic, "ld")
-	*mnemonic = append(*mnemonic, "hl")
-	operand, err := imm16_addr(r, instruction)
-	if err != nil {
-		return err
-	}
-	*mnemonic = append(*mnemonic, operand)
-	return nil
-}
-
-func decodeLD_A_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeLD_A_nn(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, "a")
 	operand, err := imm16_addr(r, instruction)
@@ -376,31 +445,31 @@ func decodeLD_A_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) er
 	return nil
 }
 
-func decodeINC_r16(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeINC_r16(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	reg_index := ((*instruction)[0] & 0x30) >> 4
 	*mnemonic = append(*mnemonic, "inc")
 	*mnemonic = append(*mnemonic, r16_sp[reg_index])
 }
 
-func decodeDEC_r16(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeDEC_r16(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	reg_index := ((*instruction)[0] & 0x30) >> 4
 	*mnemonic = append(*mnemonic, "dec")
 	*mnemonic = append(*mnemonic, r16_sp[reg_index])
 }
 
-func decodeINC_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeINC_r8(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	reg_index := ((*instruction)[0] & 0x38) >> 3
 	*mnemonic = append(*mnemonic, "inc")
 	*mnemonic = append(*mnemonic, r8[reg_index])
 }
 
-func decodeDEC_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeDEC_r8(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	reg_index := ((*instruction)[0] & 0x38) >> 3
 	*mnemonic = append(*mnemonic, "dec")
 	*mnemonic = append(*mnemonic, r8[reg_index])
 }
 
-func decodeLD_r8_n(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeLD_r8_n(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	reg_index := ((*instruction)[0] & 0x38) >> 3
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, r8[reg_index])
@@ -412,7 +481,7 @@ func decodeLD_r8_n(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) er
 	return nil
 }
 
-func decodeLD_r8_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLD_r8_r8(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	reg_dst := ((*instruction)[0] & 0x38) >> 3
 	reg_src := (*instruction)[0] & 0x7
 	*mnemonic = append(*mnemonic, "ld")
@@ -420,62 +489,61 @@ func decodeLD_r8_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, r8[reg_src])
 }
 
-func decodeALU_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeALU_r8(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	alu_op := ((*instruction)[0] & 0x38) >> 3
 	reg_index := (*instruction)[0] & 0x07
 	*mnemonic = append(*mnemonic, ALU[alu_op]...)
 	*mnemonic = append(*mnemonic, r8[reg_index])
 }
 
-func decodeRET_cc(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeRET_cc(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	cc := ((*instruction)[0] & 0x38) >> 3
+	cond, err := condition(cc)
+	if err != nil {
+		return err
+	}
 	*mnemonic = append(*mnemonic, "ret")
-	*mnemonic = append(*mnemonic, conditions[cc])
+	*mnemonic = append(*mnemonic, cond)
+	return nil
 }
 
-func decodePOP_r16(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodePOP_r16(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	reg_index := ((*instruction)[0] & 0x30) >> 4
 	*mnemonic = append(*mnemonic, "pop")
 	*mnemonic = append(*mnemonic, r16_af[reg_index])
 }
 
-func decodeJP_HL(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeJP_HL(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "jp")
 	*mnemonic = append(*mnemonic, "[hl]")
 }
 
-func decodeLD_SP_HL(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLD_SP_HL(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, "sp")
 	*mnemonic = append(*mnemonic, "hl")
 }
 
-func decodeLD_C_A(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLD_C_A(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, "[0xff00 + C]")
 	*mnemonic = append(*mnemonic, "a")
 }
 
-func decodeLD_A_C(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeLD_A_C(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, "ld")
 	*mnemonic = append(*mnemonic, "a")
 	*mnemonic = append(*mnemonic, "[0xff00 + C]")
 }
 
-func decodeJP_cc_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeJP_cc_nn(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	cc := ((*instruction)[0] & 0x38) >> 3
-	*mnemonic = append(*mnemonic, "jp")
-	*mnemonic = append(*mnemonic, conditions[cc])
-	operand, err := imm16(r, instruction)
+	cond, err := condition(cc)
 	if err != nil {
 		return err
 	}
-	*mnemonic = append(*mnemonic, operand)
-	return nil
-}
-
-func decodeJP_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "jp")
+	*mnemonic = append(*mnemonic, cond)
 	operand, err := imm16(r, instruction)
 	if err != nil {
 		return err
@@ -484,44 +552,24 @@ func decodeJP_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) erro
 	return nil
 }
 
-func decodeOUT_n_A(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	*mnemonic = append(*mnemonic, "out")
-	operand, err := imm8(r, instruction)
+func decodeJP_nn(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	*mnemonic = append(*mnemonic, "jp")
+	operand, err := imm16(r, instruction)
 	if err != nil {
 		return err
 	}
-	*mnemonic = append(*mnemonic, fmt.Sprintf("[%s]", operand))
-	*mnemonic = append(*mnemonic, "a")
+	*mnemonic = append(*mnemonic, operand)
 	return nil
 }
 
-func decodeIN_a_n(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	*mnemonic = append(*mnemonic, "in")
-	*mnemonic = append(*mnemonic, "a")
-	operand, err := imm8(r, instruction)
+func decodeCALL_cc_nn(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	cc := ((*instruction)[0] & 0x38) >> 3
+	cond, err := condition(cc)
 	if err != nil {
 		return err
 	}
-	*mnemonic = append(*mnemonic, fmt.Sprintf("[%s]", operand))
-	return nil
-}
-
-func decodeEX_SP_HL(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	*mnemonic = append(*mnemonic, "ex")
-	*mnemonic = append(*mnemonic, "[sp]")
-	*mnemonic = append(*mnemonic, "hl")
-}
-
-func decodeEX_DE_HL(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	*mnemonic = append(*mnemonic, "ex")
-	*mnemonic = append(*mnemonic, "de")
-	*mnemonic = append(*mnemonic, "hl")
-}
-
-func decodeCALL_cc_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	cc := ((*instruction)[0] & 0x38) >> 3
 	*mnemonic = append(*mnemonic, "call")
-	*mnemonic = append(*mnemonic, conditions[cc])
+	*mnemonic = append(*mnemonic, cond)
 	operand, err := imm16(r, instruction)
 	if err != nil {
 		return err
@@ -530,13 +578,13 @@ func decodeCALL_cc_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string)
 	return nil
 }
 
-func decodePUSH_r16(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodePUSH_r16(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	reg_index := ((*instruction)[0] & 0x30) >> 4
 	*mnemonic = append(*mnemonic, "push")
 	*mnemonic = append(*mnemonic, r16_af[reg_index])
 }
 
-func decodeCALL_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeCALL_nn(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "call")
 	operand, err := imm16(r, instruction)
 	if err != nil {
@@ -546,7 +594,7 @@ func decodeCALL_nn(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) er
 	return nil
 }
 
-func decodeALU_n(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeALU_n(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	alu_op := ((*instruction)[0] & 0x38) >> 3
 	*mnemonic = append(*mnemonic, ALU[alu_op]...)
 	operand, err := imm8(r, instruction)
@@ -557,20 +605,20 @@ func decodeALU_n(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) erro
 	return nil
 }
 
-func decodeRST(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeRST(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	t := ((*instruction)[0] & 0x38) >> 3
 	*mnemonic = append(*mnemonic, "rst")
 	*mnemonic = append(*mnemonic, fmt.Sprintf("0x%02x", t*8))
 }
 
-func decodeRotateShift_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeRotateShift_r8(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	op := ((*instruction)[1] & 0x38) >> 3
 	reg_index := (*instruction)[1] & 0x07
 	*mnemonic = append(*mnemonic, rotateShift[op])
 	*mnemonic = append(*mnemonic, r8[reg_index])
 }
 
-func decodeBIT_b_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeBIT_b_r8(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	bit := ((*instruction)[1] & 0x38) >> 3
 	reg_index := (*instruction)[1] & 0x07
 	*mnemonic = append(*mnemonic, "bit")
@@ -578,7 +626,7 @@ func decodeBIT_b_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, r8[reg_index])
 }
 
-func decodeRES_b_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeRES_b_r8(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	bit := ((*instruction)[1] & 0x38) >> 3
 	reg_index := (*instruction)[1] & 0x07
 	*mnemonic = append(*mnemonic, "res")
@@ -586,7 +634,7 @@ func decodeRES_b_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, r8[reg_index])
 }
 
-func decodeSET_b_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
+func decodeSET_b_r8(r Reader, instruction *[]uint8, mnemonic *[]string) {
 	bit := ((*instruction)[1] & 0x38) >> 3
 	reg_index := (*instruction)[1] & 0x07
 	*mnemonic = append(*mnemonic, "set")
@@ -594,46 +642,7 @@ func decodeSET_b_r8(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
 	*mnemonic = append(*mnemonic, r8[reg_index])
 }
 
-func decodeIN_C(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	*mnemonic = append(*mnemonic, "in")
-	*mnemonic = append(*mnemonic, "[c]")
-}
-
-func decodeIN_r8_C(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	reg_index := ((*instruction)[1] & 0x38) >> 3
-	*mnemonic = append(*mnemonic, "in")
-	*mnemonic = append(*mnemonic, r8[reg_index])
-	*mnemonic = append(*mnemonic, "[c]")
-}
-
-func decodeOUT_C(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	*mnemonic = append(*mnemonic, "out")
-	*mnemonic = append(*mnemonic, "[c]")
-	*mnemonic = append(*mnemonic, "0")
-}
-
-func decodeOUT_r8_C(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	reg_index := ((*instruction)[1] & 0x38) >> 3
-	*mnemonic = append(*mnemonic, "out")
-	*mnemonic = append(*mnemonic, "[c]")
-	*mnemonic = append(*mnemonic, r8[reg_index])
-}
-
-func decodeSBC_HL_r16(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	reg_index := ((*instruction)[1] & 0x30) >> 4
-	*mnemonic = append(*mnemonic, "sbc")
-	*mnemonic = append(*mnemonic, "hl")
-	*mnemonic = append(*mnemonic, r16_sp[reg_index])
-}
-
-func decodeADC_HL_r16(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	reg_index := ((*instruction)[1] & 0x30) >> 4
-	*mnemonic = append(*mnemonic, "adc")
-	*mnemonic = append(*mnemonic, "hl")
-	*mnemonic = append(*mnemonic, r16_sp[reg_index])
-}
-
-func decodeLD_nn_SP(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
+func decodeLD_nn_SP(r Reader, instruction *[]uint8, mnemonic *[]string) error {
 	*mnemonic = append(*mnemonic, "ld")
 	operand, err := imm16_addr(r, instruction)
 	if err != nil {
@@ -644,443 +653,650 @@ func decodeLD_nn_SP(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) e
 	return nil
 }
 
-func decodeLD_nn_r16(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	reg_index := ((*instruction)[1] & 0x30) >> 4
-	*mnemonic = append(*mnemonic, "ld")
-	operand, err := imm16_addr(r, instruction)
+/*
+ * A stream ending right after the prefix is malformed, not a read failure,
+ * and leaves the instruction as the lone 0xcb so the dangling prefix shows
+ */
+func decodePrefixCB(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	op, err := readImm(r, instruction, 1)
 	if err != nil {
 		return err
 	}
-	*mnemonic = append(*mnemonic, operand)
-	*mnemonic = append(*mnemonic, r16_sp[reg_index])
-	return nil
+	return cbOpcodes[op[0]](r, instruction, mnemonic)
 }
 
-func decodeLD_r16_nn_addr(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	reg_index := ((*instruction)[1] & 0x30) >> 4
-	*mnemonic = append(*mnemonic, "ld")
-	operand, err := imm16_addr(r, instruction)
-	if err != nil {
-		return err
-	}
-	*mnemonic = append(*mnemonic, r16_sp[reg_index])
-	*mnemonic = append(*mnemonic, operand)
-	return nil
+/*
+ * The decoder reads through this minimal interface so that a ROM can be
+ * streamed; *bytes.Reader satisfies it, and any other io.Reader can be
+ * wrapped with bufio.NewReader.
+ */
+type Reader interface {
+	io.Reader
+	io.ByteReader
 }
 
-func decodeIM_im(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	im := ((*instruction)[1] & 0x38) >> 3
-	*mnemonic = append(*mnemonic, "im")
-	*mnemonic = append(*mnemonic, interruptModes[im])
+/* A Reader that can also seek, such as *bytes.Reader */
+type ReadSeeker interface {
+	Reader
+	io.Seeker
 }
 
-func decodeLD_dst_src(dst string, src string, r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	*mnemonic = append(*mnemonic, "ld")
-	*mnemonic = append(*mnemonic, dst)
-	*mnemonic = append(*mnemonic, src)
+type limitedReader struct {
+	r Reader
+	n int64
 }
 
-func decodeBLI(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) {
-	a := (((*instruction)[1] & 0x38) >> 3) - 4
-	b := (*instruction)[1] & 0x07
-	*mnemonic = append(*mnemonic, blockInstructions[a][b])
+/*
+ * Returns a Reader that reads from r but stops with io.EOF after n bytes, so
+ * an instruction that would run past them decodes as malformed instead of
+ * reading on into whatever follows.
+ */
+func LimitReader(r Reader, n int64) Reader {
+	return &limitedReader{r, n}
 }
 
-func decodePrefixCB(r *bytes.Reader, instruction *[]uint8, mnemonic *[]string) error {
-	nextByte, err := r.ReadByte()
-	if err != nil {
-		return &Z80AsmError{errorType: Z80AsmErrorMalformedInstruction}
-	}
-	*instruction = append(*instruction, nextByte)
-
-	switch nextByte & 0xc0 {
-	case 0x00:
-		/* assorted rotate & shift operations */
-		decodeRotateShift_r8(r, instruction, mnemonic)
-	case 0x40:
-		/* bit b, r8 */
-		decodeBIT_b_r8(r, instruction, mnemonic)
-	case 0x80:
-		/* res b, r8 */
-		decodeRES_b_r8(r, instruction, mnemonic)
-	case 0xc0:
-		/* set b, r8 */
-		decodeSET_b_r8(r, instruction, mnemonic)
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
 	}
-	return err
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+func (l *limitedReader) ReadByte() (byte, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	b, err := l.r.ReadByte()
+	if err == nil {
+		l.n--
+	}
+	return b, err
 }
 
+/*
+ * The CPU whose instruction set is decoded. The two differ in a handful of
+ * primary opcodes (0x08, 0x10, 0x22/0x2A/0x32/0x3A, 0xD9, the parity and sign
+ * conditions at 0xE0-0xFA) and the 0xCB 0x30-0x37 and 0xED prefixed ones.
+ */
+type CPU uint8
+
+const (
+	/* The Game Boy's LR35902 core, the default */
+	TargetSM83 CPU = iota
+	TargetZ80
+)
+
 /*
  * Bumps the pointer in r
  * returns: the instruction bytes, the instruction mnemonic as an array of tokens
+ *
+ * addr is the logical address of the instruction, used for Addr and to
+ * resolve relative branches; it need not match the position of r, e.g. for
+ * a switchable bank read from later in the file but mapped at 0x4000.
+ *
+ * Decoding is always strict: every slot of the opcode tables is assigned a
+ * decoder or rejected as illegal, checked when the tables are built, so an
+ * instruction either has a mnemonic or an error, never neither.
+ */
+func DecodeInstruction(r Reader, addr uint32) (*GBInstruction, uint32) {
+	return DecodeInstructionFor(r, addr, TargetSM83)
+}
+
+/* Like DecodeInstruction, for the opcode slots where target's instruction set differs */
+func DecodeInstructionFor(r Reader, addr uint32, target CPU) (*GBInstruction, uint32) {
+	gbInstruction := &GBInstruction{}
+	next, ok := decodeInto(r, addr, target, gbInstruction)
+	if !ok {
+		return nil, addr
+	}
+	return gbInstruction, next
+}
+
+/*
+ * Decodes the instruction at addr into dst, appending to the truncated
+ * Instruction, Mnemonic and Comments slices of dst so their storage is reused.
+ * returns: the address of the next instruction, false on a clean EOF
  */
-func DecodeInstruction(r *bytes.Reader, addr uint32) (*GBInstruction, uint32) {
-	/* If EOF, return empty string */
-	var instruction []uint8
+func decodeInto(r Reader, addr uint32, target CPU, dst *GBInstruction) (uint32, bool) {
+	resolved, imm, disp := dst.ResolvedTarget, dst.Imm, dst.Displacement
+	*dst = GBInstruction{
+		Addr:        addr,
+		Instruction: dst.Instruction[:0],
+		Mnemonic:    dst.Mnemonic[:0],
+		Comments:    dst.Comments[:0],
+	}
+
+	/*
+	 * A clean EOF before the opcode is the end of the stream and yields no
+	 * instruction; any other read error, or a nil reader, yields an empty
+	 * instruction carrying a Z80AsmErrorReadFailure
+	 */
+	if br, ok := r.(*bytes.Reader); r == nil || ok && br == nil {
+		dst.Err = &Z80AsmError{errorType: Z80AsmErrorReadFailure, err: fmt.Errorf("nil reader")}
+		return addr, true
+	}
 	nextByte, err := r.ReadByte()
 	if err != nil {
 		if err == io.EOF {
-			return nil, addr
+			return addr, false
 		}
+		dst.Err = &Z80AsmError{errorType: Z80AsmErrorReadFailure, err: err}
+		return addr, true
 	}
 
-	instruction = append(instruction, nextByte)
-	var mnemonic []string
-
-	/* Switch on bits 6-7 */
-	switch nextByte & 0xc0 {
-	case 0x00:
-		/* Switch on bits 0-2 */
-		switch nextByte & 0x07 {
-		case 0x00:
-			/* Switch on bits 3-5 */
-			switch nextByte & 0x38 {
-			case 0x00:
-				/* nop */
-				mnemonic = append(mnemonic, "nop")
-			case 0x08:
-				/* LD [nn], sp */
-				err = decodeLD_nn_SP(r, &instruction, &mnemonic)
-			case 0x10:
-				/*
-				 * STOP
-				 */
-				mnemonic = append(mnemonic, "stop")
-			case 0x18:
-				/*
-				 * jr E - jump to PC + E
-				 */
-				err = decodeJR_E(r, &instruction, &mnemonic)
-			default:
-				/* jr nz|z|nc|c, E*/
-				err = decodeJR_cond_E(r, &instruction, &mnemonic)
-			}
-		case 0x01:
-			/* switch on bit 3 */
-			switch nextByte & 0x08 {
-			case 0x00:
-				/* ld rp[p], nn */
-				err = decodeLD_r16_nn(r, &instruction, &mnemonic)
-			case 0x08:
-				/* add hl, rp[p] */
-				decodeADD_hl_r16(r, &instruction, &mnemonic)
-			}
-		case 0x02:
-			/* switch on bit 3 */
-			switch nextByte & 0x08 {
-			case 0x00:
-				/* switch on bits 4-5 */
-				switch nextByte & 0x30 {
-				case 0x00:
-					/* ld [bc], a */
-					decodeLD_BC_A(r, &instruction, &mnemonic)
-				case 0x10:
-					/* ld [de], a */
-					decodeLD_DE_A(r, &instruction, &mnemonic)
-				case 0x20:
-					/* LDI [HL], A */
-					decodeLDI_HL_A(r, &instruction, &mnemonic)
-				case 0x30:
-					/* LDD [HL], A */
-					decodeLDD_HL_A(r, &instruction, &mnemonic)
-				}
-			case 0x08:
-				/* switch on bits 4-5 */
-				switch nextByte & 0x30 {
-				case 0x00:
-					/* ld a, [bc] */
-					decodeLD_A_BC(r, &instruction, &mnemonic)
-				case 0x10:
-					/* ld a, [de] */
-					decodeLD_A_DE(r, &instruction, &mnemonic)
-				case 0x20:
-					/* ldi A, [HL] */
-					decodeLDI_A_HL(r, &instruction, &mnemonic)
-				case 0x30:
-					/* ldd A, [HL] */
-					decodeLDD_A_HL(r, &instruction, &mnemonic)
-				}
-			}
-		case 0x03:
-			/* switch on bit 3 */
-			switch nextByte & 0x08 {
-			case 0x00:
-				/* inc r16 */
-				decodeINC_r16(r, &instruction, &mnemonic)
-			case 0x08:
-				/* dec r16 */
-				decodeDEC_r16(r, &instruction, &mnemonic)
-			}
-		case 0x04:
-			/* inc r8 */
-			decodeINC_r8(r, &instruction, &mnemonic)
-		case 0x05:
-			/* dec r8 */
-			decodeDEC_r8(r, &instruction, &mnemonic)
-		case 0x06:
-			/* ld r8, n */
-			err = decodeLD_r8_n(r, &instruction, &mnemonic)
-		case 0x07:
-			/* switch on bits 3-5 */
-			switch nextByte & 0x38 {
-			case 0x00:
-				/* RLCA */
-				mnemonic = append(mnemonic, "rlca")
-			case 0x08:
-				/* RRCA */
-				mnemonic = append(mnemonic, "rrca")
-			case 0x10:
-				/* RLA */
-				mnemonic = append(mnemonic, "rla")
-			case 0x18:
-				/* RRA */
-				mnemonic = append(mnemonic, "rra")
-			case 0x20:
-				/* DAA */
-				mnemonic = append(mnemonic, "daa")
-			case 0x28:
-				/* CPL */
-				mnemonic = append(mnemonic, "cpl")
-			case 0x30:
-				/* SCF */
-				mnemonic = append(mnemonic, "scf")
-			case 0x38:
-				/* CCF */
-				mnemonic = append(mnemonic, "ccf")
-			}
+	dst.Instruction = append(dst.Instruction, nextByte)
+	err = opcodeTable(target)[nextByte](r, &dst.Instruction, &dst.Mnemonic)
+	/* A slot that decoded without producing a mnemonic is a decoder hole, not a valid instruction */
+	if err == nil && len(dst.Mnemonic) == 0 {
+		err = &Z80AsmError{errorType: Z80AsmErrorMalformedInstruction}
+	}
+	dst.Err = err
+	if branch, ok := resolvedTarget(dst); ok {
+		if resolved == nil {
+			resolved = new(uint32)
 		}
-	case 0x40:
-		switch nextByte & 0x07 {
-		case 0x6:
-			switch nextByte & 0x38 {
-			case 0x30:
-				/* halt */
-				mnemonic = append(mnemonic, "halt")
-			default:
-				/* ld r, r' */
-				decodeLD_r8_r8(r, &instruction, &mnemonic)
-			}
-		default:
-			/* ld r, r' */
-			decodeLD_r8_r8(r, &instruction, &mnemonic)
+		*resolved = branch
+		dst.ResolvedTarget = resolved
+	}
+	if value, signed, ok := immediate(dst); ok && signed {
+		if disp == nil {
+			disp = new(int8)
 		}
-	case 0x80:
-		/* assorted ALU instructions */
-		decodeALU_r8(r, &instruction, &mnemonic)
-	case 0xc0:
-		switch nextByte & 0x07 {
-		case 0x00:
-			/* ret CC - conditional return */
-			switch nextByte & 0x38 {
-			case 0x00:
-				fallthrough
-			case 0x08:
-				fallthrough
-			case 0x10:
-				fallthrough
-			case 0x18:
-				decodeRET_cc(r, &instruction, &mnemonic)
-			case 0x20:
-				err = decodeLD_n_A(r, &instruction, &mnemonic)
-			case 0x28:
-				err = decodeADD_SP_n(r, &instruction, &mnemonic)
-			case 0x30:
-				err = decodeLD_A_n(r, &instruction, &mnemonic)
-			case 0x38:
-				err = decodeLD_HL_SP(r, &instruction, &mnemonic)
-			}
-		case 0x01:
-			switch nextByte & 0x08 {
-			case 0x00:
-				/* pop r16 */
-				decodePOP_r16(r, &instruction, &mnemonic)
-			case 0x08:
-				switch nextByte & 0x30 {
-				case 0x00:
-					/* ret */
-					mnemonic = append(mnemonic, "ret")
-				case 0x10:
-					/* reti */
-					mnemonic = append(mnemonic, "reti")
-				case 0x20:
-					/* jp hl */
-					decodeJP_HL(r, &instruction, &mnemonic)
-				case 0x30:
-					/* ld sp, hl */
-					decodeLD_SP_HL(r, &instruction, &mnemonic)
-				}
-			}
-		case 0x02:
-			/* jp cc, nn - conditional absolute jump */
-			switch nextByte & 0x38 {
-			case 0x00:
-				fallthrough
-			case 0x08:
-				fallthrough
-			case 0x10:
-				fallthrough
-			case 0x18:
-				err = decodeJP_cc_nn(r, &instruction, &mnemonic)
-			case 0x20:
-				decodeLD_C_A(r, &instruction, &mnemonic)
-			case 0x28:
-				err = decodeLD_nn_A(r, &instruction, &mnemonic)
-			case 0x30:
-				decodeLD_A_C(r, &instruction, &mnemonic)
-			case 0x38:
-				err = decodeLD_A_nn(r, &instruction, &mnemonic)
-			}
-		case 0x03:
-			switch nextByte & 0x38 {
-			case 0x00:
-				/* jp nn */
-				err = decodeJP_nn(r, &instruction, &mnemonic)
-			case 0x08:
-				/* 0xcb prefix */
-				err = decodePrefixCB(r, &instruction, &mnemonic)
-			case 0x10:
-				/* out n, a */
-				err = &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction}
-			case 0x18:
-				/* in a, n */
-				err = &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction}
-			case 0x20:
-				/* ex sp, hl */
-				err = &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction}
-			case 0x28:
-				/* ex de, hl */
-				err = &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction}
-			case 0x30:
-				/* di */
-				mnemonic = append(mnemonic, "di")
-			case 0x38:
-				/* ei */
-				mnemonic = append(mnemonic, "ei")
-			}
-		case 0x04:
-			/* call cc, nn - conditional call */
-			switch nextByte & 0x38 {
-			case 0x00:
-				fallthrough
-			case 0x08:
-				fallthrough
-			case 0x10:
-				fallthrough
-			case 0x18:
-				err = decodeCALL_cc_nn(r, &instruction, &mnemonic)
-			default:
-				err = &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction}
-			}
-		case 0x05:
-			switch nextByte & 0x08 {
-			case 0x00:
-				/* push r16 */
-				decodePUSH_r16(r, &instruction, &mnemonic)
-			case 0x08:
-				switch nextByte & 0x30 {
-				case 0x00:
-					/* call nn */
-					err = decodeCALL_nn(r, &instruction, &mnemonic)
-				case 0x10:
-					/*
-					 * DD prefix
-					 */
-					err = &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction}
-				case 0x20:
-					/* ED prefix */
-					err = &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction}
-				case 0x30:
-					/* FD prefix */
-					err = &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction}
-				}
-			}
-		case 0x06:
-			/* assorted ALU instructions */
-			err = decodeALU_n(r, &instruction, &mnemonic)
-		case 0x07:
-			/* rst p */
-			decodeRST(r, &instruction, &mnemonic)
+		*disp = int8(value)
+		dst.Displacement = disp
+	} else if ok {
+		if imm == nil {
+			imm = new(uint16)
+		}
+		*imm = value
+		dst.Imm = imm
+	}
+	dst.Flow = flowType(dst)
+	dst.Category = category(dst)
+	if target == TargetSM83 {
+		setCycles(dst)
+		if dst.Err == nil {
+			dst.Flags = flagEffects(dst)
+		}
+	}
+	return addr + uint32(dst.Len()), true
+}
+
+/*
+ * Decodes the instruction at the start of data
+ * returns: the instruction, the number of bytes of data it consumed
+ */
+func DecodeBytes(data []uint8, addr uint32) (*GBInstruction, int) {
+	gbInstruction, next := DecodeInstruction(bytes.NewReader(data), addr)
+	return gbInstruction, int(next - addr)
+}
+
+/*
+ * Decodes the single instruction at offset addr of r, leaving the position
+ * of r where it was. Decode errors are reported in the instruction's Err;
+ * the error is for a failed seek or an addr at or past the end of r.
+ */
+func DecodeAt(r ReadSeeker, addr uint32) (*GBInstruction, error) {
+	return DecodeAtOffset(r, int64(addr), addr)
+}
+
+/*
+ * Like DecodeAt, reading the instruction at file offset offset of r but
+ * placing it at logical address addr, e.g. offset 0x14000 and addr 0x4000
+ * for the start of ROM bank 5.
+ */
+func DecodeAtOffset(r ReadSeeker, offset int64, addr uint32) (*GBInstruction, error) {
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Seek(pos, io.SeekStart)
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	gbInstruction, _ := DecodeInstruction(r, addr)
+	if gbInstruction == nil {
+		return nil, fmt.Errorf("no instruction at offset 0x%04x: %w", offset, io.EOF)
+	}
+	return gbInstruction, nil
+}
+
+/*
+ * Returns the destination of a jr, djnz, jp nn, call or rst. Relative
+ * branches are resolved against the address of the following instruction,
+ * wrapping within the 16-bit address space when the branch lies in it.
+ */
+func branchTarget(i *GBInstruction) (uint32, bool) {
+	if i.Err != nil || len(i.Instruction) == 0 {
+		return 0, false
+	}
+	op := i.Instruction[0]
+	switch {
+	case op == 0x18, op&0xe7 == 0x20, op == 0x10 && i.Mnemonic[0] == "djnz":
+		/* jr e, jr cc, e, djnz e */
+		target := uint32(int64(i.Addr) + int64(len(i.Instruction)) + int64(int8(i.Instruction[1])))
+		if i.Addr <= 0xffff {
+			target &= 0xffff
 		}
+		return target, true
+	case op == 0xc3, op == 0xcd, op&0xc7 == 0xc2 && i.Mnemonic[0] == "jp", op&0xc7 == 0xc4:
+		/* jp nn, call nn, jp cc, nn, call cc, nn */
+		return uint32(binary.LittleEndian.Uint16(i.Instruction[1:])), true
+	case op&0xc7 == 0xc7:
+		/* rst p */
+		return uint32(op & 0x38), true
+	}
+	return 0, false
+}
+
+/* Returns the address an operand of i names: a branch destination, or the high-RAM address of an ldh */
+func resolvedTarget(i *GBInstruction) (uint32, bool) {
+	if target, ok := branchTarget(i); ok {
+		return target, true
+	}
+	if i.Err == nil && len(i.Instruction) == 2 && (i.Instruction[0] == 0xe0 || i.Instruction[0] == 0xf0) {
+		return 0xff00 | uint32(i.Instruction[1]), true
+	}
+	return 0, false
+}
+
+/*
+ * Returns the immediate operand of i, read from the bytes that follow the
+ * opcode and any prefix, and whether it is a signed displacement
+ */
+func immediate(i *GBInstruction) (uint16, bool, bool) {
+	if i.Err != nil {
+		return 0, false, false
+	}
+	op := i.Instruction[0]
+	operand := i.Instruction[1:]
+	switch {
+	case op == 0xcb, op == 0x10 && i.Mnemonic[0] == "stop":
+		return 0, false, false
+	case op == 0xed:
+		operand = operand[1:]
+	}
+	switch len(operand) {
+	case 1:
+		signed := op == 0x18 || op&0xe7 == 0x20 || op == 0x10 || op == 0xe8 || op == 0xf8
+		return uint16(operand[0]), signed, true
+	case 2:
+		return binary.LittleEndian.Uint16(operand), false, true
+	}
+	return 0, false, false
+}
+
+/* Returns the index among the operands of i of the one ResolvedTarget came from */
+func (i *GBInstruction) targetOperand() int {
+	if i.Instruction[0] == 0xe0 {
+		return 0
+	}
+	return len(i.Mnemonic) - 2
+}
+
+/* Returns the number of bytes the instruction occupies, including any partial bytes of a malformed one */
+func (i *GBInstruction) Len() int {
+	return len(i.Instruction)
+}
+
+/* Returns the opcode mnemonic of i, e.g. "ld", or "" if i did not decode */
+func (i *GBInstruction) Op() string {
+	if i.Err != nil || len(i.Mnemonic) == 0 {
+		return ""
 	}
-	addrPrev := addr
-	addr += uint32(len(instruction))
-	return &GBInstruction{
-		Addr:        addrPrev,
-		Instruction: instruction,
-		Mnemonic:    mnemonic,
-		Err:         err,
-        Prev:        nil,
-        Next:        nil,
-	}, addr
+	return i.Mnemonic[0]
+}
+
+/* Returns the operands of i in order, e.g. ["a", "[hl]"], or nil if i did not decode */
+func (i *GBInstruction) Operands() []string {
+	if i.Err != nil || len(i.Mnemonic) == 0 {
+		return nil
+	}
+	return i.Mnemonic[1:]
 }
 
 func (i *GBInstruction) ToStr() string {
-	instructionHex := make([]uint8, hex.EncodedLen(len(i.Instruction)))
-	hex.Encode(instructionHex, i.Instruction)
+	return i.ToStrWithOptions(FormatOptions{})
+}
+
+/*
+ * Reports whether i and other decoded the same bytes at the same address to
+ * the same tokens, with errors of the same kind. Prev, Next, comments and the
+ * metadata derived from the bytes are not compared.
+ */
+func (i *GBInstruction) Equal(other *GBInstruction) bool {
+	if i == nil || other == nil {
+		return i == other
+	}
+	return i.Addr == other.Addr &&
+		bytes.Equal(i.Instruction, other.Instruction) &&
+		slices.Equal(i.Mnemonic, other.Mnemonic) &&
+		sameErrorKind(i.Err, other.Err)
+}
+
+func sameErrorKind(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	var asmA, asmB *Z80AsmError
+	if errors.As(a, &asmA) && errors.As(b, &asmB) {
+		return asmA.errorType == asmB.errorType && asmA.illegal == asmB.illegal
+	}
+	return a.Error() == b.Error()
+}
+
+/* Implements fmt.Stringer with the default formatting of ToStr */
+func (i *GBInstruction) String() string {
+	return i.ToStr()
+}
+
+func (i *GBInstruction) ToStrWithOptions(opts FormatOptions) string {
+	return i.formatLine(&opts, i.Comments)
+}
+
+func (i *GBInstruction) formatLine(opts *FormatOptions, comments []string) string {
+	var buf [64]byte
+	return string(i.appendLine(buf[:0], opts, comments))
+}
+
+/* Appends the formatted line for i to dst without any intermediate allocations */
+func (i *GBInstruction) appendLine(dst []byte, opts *FormatOptions, comments []string) []byte {
+	dst = appendAddr(dst, i.Addr, opts)
+	dst = append(dst, ": "...)
+	start := len(dst)
+	dst = appendHex(dst, i.Instruction)
+	dst = appendPadding(dst, start, 12)
+	dst = append(dst, ' ')
+	if opts.ASCII {
+		start = len(dst)
+		dst = appendASCII(dst, i.Instruction)
+		dst = appendPadding(dst, start, 3)
+		dst = append(dst, ' ')
+	}
 	if i.Err != nil {
-		return fmt.Sprintf("0x%04x: %-12s %-6s", i.Addr, instructionHex, i.Err.Error())
+		start = len(dst)
+		dst = append(dst, i.Err.Error()...)
+		dst = appendPadding(dst, start, 6)
+	} else if len(i.Mnemonic) == 0 {
+		/* Only reachable for instructions built outside the decoder */
+		dst = append(dst, "(bad)"...)
+	} else {
+		op, operands := i.render(opts)
+		start = len(dst)
+		dst = append(dst, op...)
+		/* Without operands there is no column to align, and no trailing blanks */
+		if len(operands) > 0 {
+			dst = appendPadding(dst, start, 6)
+			dst = append(dst, ' ')
+		}
+		for n, operand := range operands {
+			if n > 0 {
+				dst = append(dst, ", "...)
+			}
+			dst = append(dst, operand...)
+		}
+	}
+	if opts.Cycles && i.Cycles != 0 {
+		comments = append([]string{formatCycles(i)}, comments...)
+	}
+	if comment, ok := opts.Comments[i.Addr]; ok {
+		comments = append(comments[:len(comments):len(comments)], comment)
+	}
+	for n, comment := range comments {
+		if n == 0 {
+			dst = append(dst, " ; "...)
+		} else {
+			dst = append(dst, "; "...)
+		}
+		dst = append(dst, comment...)
+	}
+	return dst
+}
+
+/* Appends src as text, printable ASCII as is and every other byte as '.' */
+func appendASCII(dst []byte, src []uint8) []byte {
+	for _, b := range src {
+		if b < 0x20 || b > 0x7e {
+			b = '.'
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+const hexDigits = "0123456789abcdef"
+
+/* Appends the lowercase hex encoding of src, byte-identical to hex.Encode */
+func appendHex(dst []byte, src []uint8) []byte {
+	for _, b := range src {
+		dst = append(dst, hexDigits[b>>4], hexDigits[b&0x0f])
+	}
+	return dst
+}
+
+/*
+ * Appends addr as "0x" and at least opts.AddrDigits (4 if less) hex digits,
+ * growing to fit, or as "bb:" and the digits when opts.ShowBank is set
+ */
+func appendAddr(dst []byte, addr uint32, opts *FormatOptions) []byte {
+	if opts.ShowBank {
+		if opts.Bank > 0xff {
+			dst = append(dst, hexDigits[opts.Bank>>12], hexDigits[(opts.Bank>>8)&0x0f])
+		}
+		dst = append(dst, hexDigits[(opts.Bank>>4)&0x0f], hexDigits[opts.Bank&0x0f], ':')
 	} else {
-		operands := ""
-		if len(i.Mnemonic) > 1 {
-			operands = strings.Join(i.Mnemonic[1:], ", ")
+		dst = append(dst, '0', 'x')
+	}
+	digits := 4
+	if opts.AddrDigits > digits {
+		digits = opts.AddrDigits
+	}
+	for digits < 8 && addr>>uint(digits*4) != 0 {
+		digits++
+	}
+	for shift := (digits - 1) * 4; shift >= 0; shift -= 4 {
+		dst = append(dst, hexDigits[(addr>>uint(shift))&0x0f])
+	}
+	return dst
+}
+
+/* Left-justifies everything appended since start in a field of width */
+func appendPadding(dst []byte, start int, width int) []byte {
+	for n := len(dst) - start; n < width; n++ {
+		dst = append(dst, ' ')
+	}
+	return dst
+}
+
+/*
+ * Decodes [start, end) into a slice, stopping early on any error other than
+ * an illegal or unimplemented instruction. The faulting instruction is
+ * included in the slice. Consecutive instructions are linked through
+ * Prev/Next.
+ */
+func decodeRange(r Reader, start uint32, end uint32, decode func(Reader, uint32) (*GBInstruction, uint32)) ([]*GBInstruction, error) {
+	var gbInstructions []*GBInstruction
+	for gbInstruction, addr := decode(r, start); gbInstruction != nil && gbInstruction.Addr < end; gbInstruction, addr = decode(r, addr) {
+		gbInstructions = append(gbInstructions, gbInstruction)
+		if gbInstruction.Err != nil && !IsIllegal(gbInstruction.Err) && !IsUnimplemented(gbInstruction.Err) {
+			LinkInstructions(gbInstructions)
+			return gbInstructions, gbInstruction.Err
+		}
+	}
+	LinkInstructions(gbInstructions)
+	return gbInstructions, nil
+}
+
+/*
+ * Links insns, in address order, through Prev and Next, replacing any
+ * previous links; the first has no Prev and the last no Next
+ */
+func LinkInstructions(insns []*GBInstruction) {
+	for n, i := range insns {
+		i.Prev, i.Next = nil, nil
+		if n > 0 {
+			i.Prev = insns[n-1]
+		}
+		if n+1 < len(insns) {
+			i.Next = insns[n+1]
+		}
+	}
+}
+
+/*
+ * Decodes the instructions in [start, end), r positioned at start.
+ * Illegal and unimplemented instructions are kept and decoding continues past
+ * them; any other error ends decoding and is returned along with everything
+ * decoded so far, the faulting instruction included.
+ */
+func Disassemble(r Reader, start uint32, end uint32) ([]*GBInstruction, error) {
+	return decodeRange(r, start, end, DecodeInstruction)
+}
+
+/*
+ * Decodes up to n instructions from r, positioned at start, with the same
+ * error handling as Disassemble. Fewer are returned if r runs out first.
+ */
+func DisassembleN(r Reader, start uint32, n int) ([]*GBInstruction, error) {
+	var gbInstructions []*GBInstruction
+	var err error
+	/* Checking the count first leaves r just past the last instruction returned */
+	for addr := start; len(gbInstructions) < n; {
+		var gbInstruction *GBInstruction
+		if gbInstruction, addr = DecodeInstruction(r, addr); gbInstruction == nil {
+			break
 		}
-		return fmt.Sprintf("0x%04x: %-12s %-6s %s", i.Addr, instructionHex, i.Mnemonic[0], operands)
+		gbInstructions = append(gbInstructions, gbInstruction)
+		if gbInstruction.Err != nil && !IsIllegal(gbInstruction.Err) && !IsUnimplemented(gbInstruction.Err) {
+			err = gbInstruction.Err
+			break
+		}
+	}
+	LinkInstructions(gbInstructions)
+	return gbInstructions, err
+}
+
+/* Like Disassemble, for [start, end) of the file at path, addresses being file offsets */
+func DisassembleFile(path string, start uint32, end uint32) ([]*GBInstruction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("disassembling %s: %w", path, err)
 	}
+	reader := bytes.NewReader(data)
+	reader.Seek(int64(start), io.SeekStart)
+	return Disassemble(reader, start, end)
 }
 
-func DisassemblerLoop(r *bytes.Reader, start uint32, end uint32) int {
-	var addr uint32 = start
-	for gbInstruction, addr := DecodeInstruction(r, addr); gbInstruction != nil && gbInstruction.Addr < end; gbInstruction, addr = DecodeInstruction(r, addr) {
-		/* Generate hex encoding of instruction */
-		fmt.Printf("%s\n", gbInstruction.ToStr())
+/*
+ * Iterates over the instructions in [start, end), r positioned at start, with
+ * the same error handling as Disassemble: illegal and unimplemented
+ * instructions are yielded with a nil error and only carry it in Err, any
+ * other error is yielded with its instruction and ends the iteration.
+ * Instructions are not linked through Prev and Next.
+ */
+func Instructions(r Reader, start uint32, end uint32) iter.Seq2[*GBInstruction, error] {
+	return func(yield func(*GBInstruction, error) bool) {
+		for gbInstruction, addr := DecodeInstruction(r, start); gbInstruction != nil && gbInstruction.Addr < end; gbInstruction, addr = DecodeInstruction(r, addr) {
+			if gbInstruction.Err != nil && !IsIllegal(gbInstruction.Err) && !IsUnimplemented(gbInstruction.Err) {
+				yield(gbInstruction, gbInstruction.Err)
+				return
+			}
+			if !yield(gbInstruction, nil) {
+				return
+			}
+		}
+	}
+}
 
-		if gbInstruction.Err != nil &&
-			gbInstruction.Err.(*Z80AsmError).errorType != Z80AsmErrorIllegalInstruction &&
-			gbInstruction.Err.(*Z80AsmError).errorType != Z80AsmErrorUnimplementedInstruction {
-			return 1
+/*
+ * Disassembles [start, end) like Disassemble and writes one line per
+ * instruction to w. Returns the number of lines written and the first
+ * decoding or write error.
+ */
+func DisassembleTo(w io.Writer, r Reader, start uint32, end uint32) (int, error) {
+	gbInstructions, err := Disassemble(r, start, end)
+	for n, gbInstruction := range gbInstructions {
+		if _, werr := fmt.Fprintf(w, "%s\n", gbInstruction.ToStr()); werr != nil {
+			return n, werr
 		}
+	}
+	return len(gbInstructions), err
+}
 
+func DisassemblerLoop(r Reader, start uint32, end uint32) int {
+	if _, err := DisassembleTo(os.Stdout, r, start, end); err != nil {
+		return 1
 	}
 	return 0
 }
 
-func GBROMPreamble(reader *bytes.Reader) int {
-	/* 0x0000 - 0x0067 contains the RST and Interrupt tables */
-	reader.Seek(int64(0x0000), 0)
-	fmt.Printf("---------------- %-40s ----------------\n", "RST and Interrupt table")
-	ret := DisassemblerLoop(reader, 0x0000, 0x0068)
-	if ret != 0 {
-		fmt.Printf("Oh noes!\n")
-		return ret
+/* How GBROMPreambleTo lists an image; the zero value lists a cartridge with its vectors labelled */
+type PreambleOptions struct {
+	/* Formats the listing, nil for the defaults; its Labels are replaced */
+	Disassembler *Disassembler
+	/* Analyze the image as a DMG boot ROM, see AnalyzeOptions */
+	BootROM bool
+	/* Label every branch target, see GenerateLabels; not done under a Layout */
+	GenerateLabels bool
+	/* Names that take precedence over VectorLabels and generated labels, e.g. from LoadSymbols */
+	Symbols map[uint32]string
+	/* List the image segment by segment instead of analyzing it, see SegmentedDisassemble */
+	Layout []Segment
+}
+
+/*
+ * Writes the sections found by AnalyzeROM to w, or under opts.Layout every
+ * segment of the image. Returns the error that cut the analysis short, after
+ * writing everything decoded before it.
+ */
+func GBROMPreambleTo(w io.Writer, reader *bytes.Reader, opts PreambleOptions) error {
+	var d Disassembler
+	if opts.Disassembler != nil {
+		d = *opts.Disassembler
+	}
+	rom := make([]uint8, reader.Size())
+	if _, err := reader.ReadAt(rom, 0); err != nil && err != io.EOF {
+		return err
 	}
 
-	/*
-	 * Code entry point is at 0x0100-0x0103
-	 * It is almost always nop followed by jp
-	 */
-	fmt.Printf("\n")
-	fmt.Printf("---------------- %-40s ----------------\n", "Code Entry Point (Trampoline)")
-	var addr uint32 = 0x0100
-	reader.Seek(int64(addr), 0)
-	var gbInstruction *GBInstruction
-	for gbInstruction, addr = DecodeInstruction(reader, addr); gbInstruction != nil && gbInstruction.Instruction[0] == 0x00; /* while nops */
-	gbInstruction, addr = DecodeInstruction(reader, addr) {
-		fmt.Printf("%s\n", gbInstruction.ToStr())
-	}
-	fmt.Printf("%s\n", gbInstruction.ToStr())
-
-	fmt.Printf("\n")
-	fmt.Printf("---------------- %-40s ----------------\n", "Code Start")
-	var target uint16
-	switch gbInstruction.Instruction[0] {
-	case 0xc3: /* jp */
-		/* compute the offset of the jp */
-		target = binary.LittleEndian.Uint16(gbInstruction.Instruction[1:])
-		reader.Seek(int64(target), 0)
-		return DisassemblerLoop(reader, uint32(target), uint32(0x8000))
-	default:
+	if opts.Layout != nil {
+		d.Options.Labels = mergeLabels(nil, false, nil, opts.Symbols)
+		return SegmentedDisassemble(w, &d, rom, opts.Layout)
+	}
+
+	analysis, err := AnalyzeROM(rom, AnalyzeOptions{BootROM: opts.BootROM})
+	var all []*GBInstruction
+	all = append(all, analysis.RSTTable...)
+	all = append(all, analysis.Trampoline...)
+	all = append(all, analysis.Code...)
+	if analysis.BootROM {
+		d.Options.Labels = mergeLabels(all, opts.GenerateLabels, nil, opts.Symbols)
+		writeSection(w, &d, "Boot ROM", analysis.Code)
+		return err
+	}
+	d.Options.Labels = mergeLabels(all, opts.GenerateLabels, VectorLabels, opts.Symbols)
+	writeSection(w, &d, "RST and Interrupt table", analysis.RSTTable)
+	fmt.Fprintf(w, "\n")
+	writeSection(w, &d, "Code Entry Point (Trampoline)", analysis.Trampoline)
+	fmt.Fprintf(w, "\n")
+	if analysis.Logo != nil {
+		writeSection(w, &d, "Nintendo Logo", analysis.Logo)
+		fmt.Fprintf(w, "\n")
+	}
+	writeSection(w, &d, "Code Start", analysis.Code)
+	return err
+}
+
+/* Prints the sections found by AnalyzeROM, returning 1 if the entry point could not be followed */
+func GBROMPreamble(reader *bytes.Reader) int {
+	if err := GBROMPreambleTo(os.Stdout, reader, PreambleOptions{}); err != nil {
 		fmt.Printf("Oh noes!\n")
 		return 1
 	}
-	return 1
+	return 0
 }
@@ -0,0 +1,519 @@
+package gobjdump
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAppendHex(t *testing.T) {
+	for _, src := range [][]uint8{nil, {0x00}, {0xcb, 0x7e}, {0xc3, 0x50, 0x01}, {0xff, 0x0f, 0xf0, 0xa5}} {
+		if got, want := string(appendHex(nil, src)), hex.EncodeToString(src); got != want {
+			t.Errorf("appendHex(% x) = %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestAppendAddr(t *testing.T) {
+	for _, addr := range []uint32{0x0000, 0x0150, 0xffff, 0x10000, 0x123456, 0xffffffff} {
+		if got, want := string(appendAddr(nil, addr, &FormatOptions{})), fmt.Sprintf("0x%04x", addr); got != want {
+			t.Errorf("appendAddr(0x%x) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+/* The line format from before appendLine, kept to check and benchmark against */
+func sprintfLine(i *GBInstruction) string {
+	var line string
+	if i.Err != nil {
+		line = fmt.Sprintf("0x%04x: %-12s %-6s", i.Addr, hex.EncodeToString(i.Instruction), i.Err.Error())
+	} else {
+		line = fmt.Sprintf("0x%04x: %-12s %-6s %s", i.Addr, hex.EncodeToString(i.Instruction), i.Op(), strings.Join(i.Operands(), ", "))
+	}
+	if len(i.Comments) > 0 {
+		line += " ; " + strings.Join(i.Comments, "; ")
+	}
+	return line
+}
+
+var formatLineProgram = []uint8{
+	0x3e, 0x12, /* ld a, 0x12 */
+	0xea, 0x00, 0xc0, /* ld [0xc000], a */
+	0xcb, 0x7e, /* bit 7, [hl] */
+	0x20, 0xf7, /* jr nz, -9 */
+	0xd3, /* illegal */
+	0xc9, /* ret */
+}
+
+func TestFormatLineMatchesSprintf(t *testing.T) {
+	insns, _ := Disassemble(bytes.NewReader(formatLineProgram), 0x0150, 0x0150+uint32(len(formatLineProgram)))
+	insns[0].Comments = []string{"one", "two"}
+	for _, i := range insns {
+		if got, want := i.ToStr(), strings.TrimRight(sprintfLine(i), " "); got != want {
+			t.Errorf("ToStr = %q, want %q", got, want)
+		}
+	}
+}
+
+func BenchmarkFormatLine(b *testing.B) {
+	insns, _ := Disassemble(bytes.NewReader(formatLineProgram), 0x0150, 0x0150+uint32(len(formatLineProgram)))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		for _, i := range insns {
+			i.ToStr()
+		}
+	}
+}
+
+func BenchmarkFormatLineSprintf(b *testing.B) {
+	insns, _ := Disassemble(bytes.NewReader(formatLineProgram), 0x0150, 0x0150+uint32(len(formatLineProgram)))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		for _, i := range insns {
+			sprintfLine(i)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []uint8
+		end       uint32
+		wantAddrs []uint32
+		wantErr   func(error) bool
+	}{
+		{"continue on illegal", []uint8{0x00, 0xd3, 0xdd, 0x3c}, 0x0154, []uint32{0x0150, 0x0151, 0x0152, 0x0153}, nil},
+		{"stop on malformed", []uint8{0x00, 0xc3, 0x50}, 0x0160, []uint32{0x0150, 0x0151}, IsMalformed},
+		{"stop at end", []uint8{0x00, 0x00, 0x00, 0x00}, 0x0152, []uint32{0x0150, 0x0151}, nil},
+		{"end of input", []uint8{0x00, 0x3c}, 0x0160, []uint32{0x0150, 0x0151}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, err := Disassemble(bytes.NewReader(tt.data), 0x0150, tt.end)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !tt.wantErr(err) {
+				t.Errorf("err = %v", err)
+			}
+			var addrs []uint32
+			for _, i := range insns {
+				addrs = append(addrs, i.Addr)
+			}
+			if !slices.Equal(addrs, tt.wantAddrs) {
+				t.Errorf("addresses %x, want %x", addrs, tt.wantAddrs)
+			}
+			if tt.wantErr != nil && insns[len(insns)-1].Err != err {
+				t.Errorf("last instruction %s does not carry the error", insns[len(insns)-1])
+			}
+		})
+	}
+}
+
+func TestToStrWithoutMnemonic(t *testing.T) {
+	tests := []struct {
+		name string
+		i    *GBInstruction
+		want string
+	}{
+		{"built by hand", &GBInstruction{Addr: 0x0150, Instruction: []uint8{0x00}}, "0x0150: 00           (bad)"},
+		{"no bytes", &GBInstruction{Addr: 0x0150}, "0x0150:              (bad)"},
+		{"error", &GBInstruction{Addr: 0x0150, Instruction: []uint8{0xd3}, Err: &Z80AsmError{}}, "0x0150: d3           Illegal Instruction"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.i.ToStr(); got != tt.want {
+				t.Errorf("ToStr = %q, want %q", got, tt.want)
+			}
+			if tt.i.Op() != "" && tt.i.Err == nil {
+				t.Errorf("Op = %q, want none", tt.i.Op())
+			}
+		})
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	program := []uint8{0x3e, 0x12, 0xd3, 0xcb, 0x7e, 0x20, 0xf9, 0xc9}
+	var got []*GBInstruction
+	for i, err := range Instructions(bytes.NewReader(program), 0x0150, 0x0158) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, i)
+	}
+
+	r := bytes.NewReader(program)
+	var want []*GBInstruction
+	for i, addr := DecodeInstruction(r, 0x0150); i != nil && i.Addr < 0x0158; i, addr = DecodeInstruction(r, addr) {
+		want = append(want, i)
+	}
+	if len(got) != len(want) || len(got) != 5 {
+		t.Fatalf("iterated %d instructions, decoded %d, want 5", len(got), len(want))
+	}
+	for n := range got {
+		if !got[n].Equal(want[n]) {
+			t.Errorf("instruction %d: %s, want %s", n, got[n], want[n])
+		}
+	}
+}
+
+func TestInstructionsStop(t *testing.T) {
+	/* Breaking out of the loop stops decoding */
+	r := bytes.NewReader([]uint8{0x00, 0x00, 0x00, 0x00})
+	for i := range Instructions(r, 0x0150, 0x0154) {
+		if i.Addr == 0x0151 {
+			break
+		}
+	}
+	if r.Len() != 2 {
+		t.Errorf("%d bytes left after breaking at the second instruction, want 2", r.Len())
+	}
+
+	/* A malformed instruction is yielded with its error and ends the iteration */
+	var errs []error
+	for _, err := range Instructions(bytes.NewReader([]uint8{0x00, 0xc3, 0x50}), 0x0150, 0x0160) {
+		errs = append(errs, err)
+	}
+	if len(errs) != 2 || errs[0] != nil || !IsMalformed(errs[1]) {
+		t.Errorf("errors %v, want nil then malformed", errs)
+	}
+}
+
+func TestLen(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		want int
+	}{
+		{"one byte", []uint8{0x00}, 1},
+		{"imm8", []uint8{0x3e, 0x12}, 2},
+		{"imm16", []uint8{0x01, 0x34, 0x12}, 3},
+		{"cb", []uint8{0xcb, 0x37}, 2},
+		{"illegal", []uint8{0xd3, 0x00}, 1},
+		{"truncated imm16", []uint8{0x01, 0x34}, 2},
+		{"truncated imm8", []uint8{0x3e}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, next := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if i.Len() != tt.want {
+				t.Errorf("Len = %d, want %d", i.Len(), tt.want)
+			}
+			if int(next-0x0150) != i.Len() {
+				t.Errorf("next = 0x%04x, want Len bytes on", next)
+			}
+		})
+	}
+}
+
+func TestDecodeAt(t *testing.T) {
+	data := []uint8{0x00, 0x3e, 0x12, 0xc3, 0x50, 0x01, 0xcb, 0x37}
+	tests := []struct {
+		addr uint32
+		want string
+	}{
+		{0x0000, "0x0000: 00           nop"},
+		{0x0001, "0x0001: 3e12         ld     a, 0x12"},
+		{0x0003, "0x0003: c35001       jp     0x0150"},
+		{0x0006, "0x0006: cb37         swap   a"},
+		/* Mid-instruction offsets decode whatever is there */
+		{0x0004, "0x0004: 50           ld     d, b"},
+	}
+	r := bytes.NewReader(data)
+	r.Seek(2, io.SeekStart)
+	for _, tt := range tests {
+		i, err := DecodeAt(r, tt.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := i.ToStr(); got != tt.want {
+			t.Errorf("DecodeAt(0x%04x) = %q, want %q", tt.addr, got, tt.want)
+		}
+		if pos, _ := r.Seek(0, io.SeekCurrent); pos != 2 {
+			t.Errorf("DecodeAt(0x%04x) moved the reader to %d", tt.addr, pos)
+		}
+	}
+	if _, err := DecodeAt(r, uint32(len(data))); !errors.Is(err, io.EOF) {
+		t.Errorf("DecodeAt past the end: err = %v, want io.EOF", err)
+	}
+}
+
+func TestOpOperands(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []uint8
+		wantOp       string
+		wantOperands []string
+	}{
+		{"two operands", []uint8{0x78}, "ld", []string{"a", "b"}},
+		{"memory operand", []uint8{0x7e}, "ld", []string{"a", "[hl]"}},
+		{"one operand", []uint8{0xc3, 0x50, 0x01}, "jp", []string{"0x0150"}},
+		{"no operands", []uint8{0x00}, "nop", []string{}},
+		{"error", []uint8{0xd3}, "", nil},
+		{"malformed", []uint8{0x3e}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if i.Op() != tt.wantOp {
+				t.Errorf("Op = %q, want %q", i.Op(), tt.wantOp)
+			}
+			if got := i.Operands(); !slices.Equal(got, tt.wantOperands) || (got == nil) != (tt.wantOperands == nil) {
+				t.Errorf("Operands = %#v, want %#v", got, tt.wantOperands)
+			}
+		})
+	}
+}
+
+/* Fails every write after the first n */
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errFlaky
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestDisassembleTo(t *testing.T) {
+	program := []uint8{0x3e, 0x12, 0xd3, 0x3d}
+	var buf bytes.Buffer
+	n, err := DisassembleTo(&buf, bytes.NewReader(program), 0x0150, 0x0154)
+	if err != nil || n != 3 {
+		t.Fatalf("DisassembleTo = %d, %v; want 3, nil", n, err)
+	}
+	want := "0x0150: 3e12         ld     a, 0x12\n" +
+		"0x0152: d3           Illegal Instruction\n" +
+		"0x0153: 3d           dec    a\n"
+	if buf.String() != want {
+		t.Errorf("wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	n, err = DisassembleTo(&failingWriter{n: 1}, bytes.NewReader(program), 0x0150, 0x0154)
+	if n != 1 || !errors.Is(err, errFlaky) {
+		t.Errorf("with a failing writer: %d, %v; want 1 and the write error", n, err)
+	}
+	buf.Reset()
+	n, err = DisassembleTo(&buf, bytes.NewReader([]uint8{0x00, 0xc3}), 0x0150, 0x0160)
+	if n != 2 || !IsMalformed(err) || !strings.HasSuffix(buf.String(), "Malformed Instruction\n") {
+		t.Errorf("malformed input: %d, %v, %q", n, err, buf.String())
+	}
+}
+
+func TestTypedImmediates(t *testing.T) {
+	imm := func(v uint16) *uint16 { return &v }
+	disp := func(v int8) *int8 { return &v }
+	tests := []struct {
+		name     string
+		data     []uint8
+		wantImm  *uint16
+		wantDisp *int8
+	}{
+		{"ld bc, nn", []uint8{0x01, 0x34, 0x12}, imm(0x1234), nil},
+		{"ld a, n", []uint8{0x3e, 0x0f}, imm(0x0f), nil},
+		{"ldh", []uint8{0xe0, 0x44}, imm(0x44), nil},
+		{"jp nn", []uint8{0xc3, 0x50, 0x01}, imm(0x0150), nil},
+		{"ld [nn], a", []uint8{0xea, 0x00, 0xc0}, imm(0xc000), nil},
+		{"jr", []uint8{0x18, 0xfe}, nil, disp(-2)},
+		{"add sp, e", []uint8{0xe8, 0xfd}, nil, disp(-3)},
+		{"ld hl, sp+e", []uint8{0xf8, 0x02}, nil, disp(2)},
+		{"ld a, b", []uint8{0x78}, nil, nil},
+		{"rst", []uint8{0xef}, nil, nil},
+		{"malformed", []uint8{0x01, 0x34}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if (i.Imm == nil) != (tt.wantImm == nil) || i.Imm != nil && *i.Imm != *tt.wantImm {
+				t.Errorf("Imm = %v, want %v", i.Imm, tt.wantImm)
+			}
+			if (i.Displacement == nil) != (tt.wantDisp == nil) || i.Displacement != nil && *i.Displacement != *tt.wantDisp {
+				t.Errorf("Displacement = %v, want %v", i.Displacement, tt.wantDisp)
+			}
+		})
+	}
+}
+
+/* Bank 1 data read from its file offset decodes at its 0x4000 logical base */
+func TestDecodeBankOneLogicalBase(t *testing.T) {
+	rom := make([]uint8, 2*ROMBankSize)
+	/* 0x4000: jr 0x4004; nop; nop; 0x4004: jp 0x4000; 0x4007: call 0x4010 */
+	copy(rom[ROMBankSize:], []uint8{0x18, 0x02, 0x00, 0x00, 0xc3, 0x00, 0x40, 0xcd, 0x10, 0x40})
+	r := bytes.NewReader(rom)
+	r.Seek(ROMBankSize, io.SeekStart)
+	insns, err := DisassembleN(r, 0x4000, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr, target uint32
+	}{
+		{0x4000, 0x4004},
+		{0x4004, 0x4000},
+		{0x4007, 0x4010},
+	}
+	for _, tt := range tests {
+		i := InstructionAt(insns, tt.addr)
+		if i == nil || i.Addr != tt.addr {
+			t.Fatalf("no instruction at 0x%04x in %v", tt.addr, insns)
+		}
+		if i.ResolvedTarget == nil || *i.ResolvedTarget != tt.target {
+			t.Errorf("%s: target %v, want 0x%04x", i, i.ResolvedTarget, tt.target)
+		}
+	}
+
+	i, err := DecodeAtOffset(bytes.NewReader(rom), ROMBankSize+4, 0x4004)
+	if err != nil || i.ToStr() != "0x4004: c30040       jp     0x4000" {
+		t.Errorf("DecodeAtOffset = %v, %v", i, err)
+	}
+}
+
+func TestDecodeAtOffset(t *testing.T) {
+	rom := make([]uint8, 3*ROMBankSize)
+	copy(rom[2*ROMBankSize:], []uint8{0x18, 0xfe})
+	i, err := DecodeAtOffset(bytes.NewReader(rom), 2*ROMBankSize, 0x4000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Addr != 0x4000 || i.ResolvedTarget == nil || *i.ResolvedTarget != 0x4000 {
+		t.Errorf("decoded %s, want jr -2 at and to 0x4000", i)
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, data := range [][]uint8{{0x00}, {0xcb, 0x7e}, {0xd3}, {0xc3, 0x50}} {
+		i, _ := DecodeInstruction(bytes.NewReader(data), 0x0150)
+		i.Comments = []string{"note"}
+		for _, format := range []string{"%v", "%s"} {
+			if got := fmt.Sprintf(format, i); got != i.ToStr() {
+				t.Errorf("%s of % x = %q, want %q", format, data, got, i.ToStr())
+			}
+		}
+	}
+}
+
+func TestDisassembleFile(t *testing.T) {
+	path := writeTemp(t, "prog.bin", []uint8{0xff, 0xff, 0x3e, 0x12, 0xc9})
+	insns, err := DisassembleFile(path, 0x0002, 0x0005)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, i := range insns {
+		got = append(got, i.ToStr())
+	}
+	want := []string{"0x0002: 3e12         ld     a, 0x12", "0x0004: c9           ret"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := DisassembleFile(path+".missing", 0, 1); err == nil || !strings.Contains(err.Error(), "prog.bin.missing") {
+		t.Errorf("missing file: err = %v, want one naming the file", err)
+	}
+}
+
+func TestLinkInstructions(t *testing.T) {
+	program := []uint8{0x3e, 0x12, 0x00, 0xc9}
+	insns, _ := Disassemble(bytes.NewReader(program), 0x0150, 0x0154)
+	if len(insns) != 3 {
+		t.Fatalf("got %d instructions, want 3", len(insns))
+	}
+	var forward, backward []string
+	for i := insns[0]; i != nil; i = i.Next {
+		forward = append(forward, i.Op())
+	}
+	for i := insns[2]; i != nil; i = i.Prev {
+		backward = append(backward, i.Op())
+	}
+	if want := []string{"ld", "nop", "ret"}; !slices.Equal(forward, want) {
+		t.Errorf("forward %q, want %q", forward, want)
+	}
+	if want := []string{"ret", "nop", "ld"}; !slices.Equal(backward, want) {
+		t.Errorf("backward %q, want %q", backward, want)
+	}
+
+	/* Relinking a subslice cuts it loose from its neighbours */
+	LinkInstructions(insns[1:2])
+	if insns[1].Prev != nil || insns[1].Next != nil {
+		t.Errorf("relinked nop still has Prev %v, Next %v", insns[1].Prev, insns[1].Next)
+	}
+
+	n, _ := DisassembleN(bytes.NewReader(program), 0x0150, 2)
+	if n[0].Next != n[1] || n[1].Prev != n[0] || n[1].Next != nil {
+		t.Error("DisassembleN does not link its instructions")
+	}
+}
+
+func TestDisassembleN(t *testing.T) {
+	/* ld a, 0x12; nop; ld hl, 0x1234; xor a; inc a; ret; nop; nop */
+	program := []uint8{0x3e, 0x12, 0x00, 0x21, 0x34, 0x12, 0xaf, 0x3c, 0xc9, 0x00}
+	tests := []struct {
+		name     string
+		n        int
+		want     []uint32
+		wantRest int
+	}{
+		{"five", 5, []uint32{0x0150, 0x0152, 0x0153, 0x0156, 0x0157}, 2},
+		{"none", 0, nil, 10},
+		{"more than there are", 20, []uint32{0x0150, 0x0152, 0x0153, 0x0156, 0x0157, 0x0158, 0x0159}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(program)
+			insns, err := DisassembleN(r, 0x0150, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var addrs []uint32
+			for _, i := range insns {
+				addrs = append(addrs, i.Addr)
+			}
+			if !slices.Equal(addrs, tt.want) {
+				t.Errorf("addresses %x, want %x", addrs, tt.want)
+			}
+			if r.Len() != tt.wantRest {
+				t.Errorf("%d bytes left unread, want %d", r.Len(), tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	decode := func(data []uint8, addr uint32) *GBInstruction {
+		i, _ := DecodeInstruction(bytes.NewReader(data), addr)
+		return i
+	}
+	commented := decode([]uint8{0x3e, 0x12}, 0x0150)
+	commented.Comments = []string{"load"}
+	tests := []struct {
+		name string
+		a, b *GBInstruction
+		want bool
+	}{
+		{"independent decodes", decode([]uint8{0x3e, 0x12}, 0x0150), decode([]uint8{0x3e, 0x12}, 0x0150), true},
+		{"different immediate", decode([]uint8{0x3e, 0x12}, 0x0150), decode([]uint8{0x3e, 0x13}, 0x0150), false},
+		{"different opcode", decode([]uint8{0x00}, 0x0150), decode([]uint8{0xc9}, 0x0150), false},
+		{"different address", decode([]uint8{0x00}, 0x0150), decode([]uint8{0x00}, 0x0151), false},
+		{"comments ignored", decode([]uint8{0x3e, 0x12}, 0x0150), commented, true},
+		{"same illegal opcode", decode([]uint8{0xd3}, 0x0150), decode([]uint8{0xd3}, 0x0150), true},
+		{"truncated vs whole", decode([]uint8{0x3e}, 0x0150), decode([]uint8{0x3e, 0x12}, 0x0150), false},
+		{"both nil", nil, nil, true},
+		{"one nil", decode([]uint8{0x00}, 0x0150), nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Equal(tt.b); got != tt.want {
+				t.Errorf("a.Equal(b) = %v, want %v", got, tt.want)
+			}
+			if got := tt.b.Equal(tt.a); got != tt.want {
+				t.Errorf("b.Equal(a) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
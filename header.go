@@ -0,0 +1,316 @@
+package gobjdump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const ROMBankSize = 0x4000
+
+const (
+	HeaderStart = 0x0100
+	HeaderEnd   = 0x0150
+)
+
+/* The Nintendo logo at 0x0104-0x0133 */
+const (
+	LogoStart = 0x0104
+	LogoEnd   = 0x0134
+)
+
+/* The logo the boot ROM compares against; it locks up if the cartridge's differs */
+var NintendoLogo = [LogoEnd - LogoStart]uint8{
+	0xce, 0xed, 0x66, 0x66, 0xcc, 0x0d, 0x00, 0x0b, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0c, 0x00, 0x0d,
+	0x00, 0x08, 0x11, 0x1f, 0x88, 0x89, 0x00, 0x0e, 0xdc, 0xcc, 0x6e, 0xe6, 0xdd, 0xdd, 0xd9, 0x99,
+	0xbb, 0xbb, 0x67, 0x63, 0x6e, 0x0e, 0xec, 0xcc, 0xdd, 0xdc, 0x99, 0x9f, 0xbb, 0xb9, 0x33, 0x3e,
+}
+
+/* The CGB flag at 0x0143 */
+type CGBSupport uint8
+
+const (
+	/* A DMG cartridge; the byte is part of the title */
+	CGBNone CGBSupport = iota
+	/* 0x80: enhanced for CGB, still runs on DMG */
+	CGBCompatible
+	/* 0xC0: refuses to run on DMG */
+	CGBOnly
+)
+
+var cgbSupportNames = [...]string{
+	CGBNone:       "DMG",
+	CGBCompatible: "CGB compatible",
+	CGBOnly:       "CGB only",
+}
+
+func (c CGBSupport) String() string {
+	if int(c) < len(cgbSupportNames) {
+		return cgbSupportNames[c]
+	}
+	return "unknown"
+}
+
+/* The SGB flag at 0x0146; only 0x03 enables SGB functions */
+type SGBSupport uint8
+
+const (
+	SGBNone SGBSupport = iota
+	SGBSupported
+)
+
+func (s SGBSupport) String() string {
+	if s == SGBSupported {
+		return "SGB"
+	}
+	return "no SGB"
+}
+
+/* The cartridge header at 0x0100-0x014F */
+type CartHeader struct {
+	/* The header bytes as read, indexed from 0x0100 */
+	Raw [HeaderEnd - HeaderStart]uint8
+
+	Title string
+	CGB   CGBSupport
+	SGB   SGBSupport
+	/* Two ASCII characters at 0x0144, only meaningful when OldLicensee is 0x33 */
+	NewLicensee string
+	/* Licensee code at 0x014B, see LicenseeName */
+	OldLicensee uint8
+	/* MBC and extra hardware code at 0x0147 */
+	CartridgeType uint8
+	/* Raw ROM size code at 0x0148 */
+	ROMSize uint8
+	/* Raw RAM size code at 0x0149 */
+	RAMSize        uint8
+	Version        uint8
+	HeaderChecksum uint8
+	/* Stored big-endian at 0x014E */
+	GlobalChecksum uint16
+}
+
+var cartridgeTypes = map[uint8]string{
+	0x00: "ROM ONLY",
+	0x01: "MBC1",
+	0x02: "MBC1+RAM",
+	0x03: "MBC1+RAM+BATTERY",
+	0x05: "MBC2",
+	0x06: "MBC2+BATTERY",
+	0x08: "ROM+RAM",
+	0x09: "ROM+RAM+BATTERY",
+	0x0b: "MMM01",
+	0x0c: "MMM01+RAM",
+	0x0d: "MMM01+RAM+BATTERY",
+	0x0f: "MBC3+TIMER+BATTERY",
+	0x10: "MBC3+TIMER+RAM+BATTERY",
+	0x11: "MBC3",
+	0x12: "MBC3+RAM",
+	0x13: "MBC3+RAM+BATTERY",
+	0x19: "MBC5",
+	0x1a: "MBC5+RAM",
+	0x1b: "MBC5+RAM+BATTERY",
+	0x1c: "MBC5+RUMBLE",
+	0x1d: "MBC5+RUMBLE+RAM",
+	0x1e: "MBC5+RUMBLE+RAM+BATTERY",
+	0x20: "MBC6",
+	0x22: "MBC7+SENSOR+RUMBLE+RAM+BATTERY",
+	0xfc: "POCKET CAMERA",
+	0xfd: "BANDAI TAMA5",
+	0xfe: "HuC3",
+	0xff: "HuC1+RAM+BATTERY",
+}
+
+/* Reads and decodes the cartridge header of the ROM in r */
+func ParseHeader(r io.ReadSeeker) (*CartHeader, error) {
+	if _, err := r.Seek(HeaderStart, io.SeekStart); err != nil {
+		return nil, err
+	}
+	h := &CartHeader{}
+	if _, err := io.ReadFull(r, h.Raw[:]); err != nil {
+		return nil, fmt.Errorf("reading cartridge header: %w", err)
+	}
+
+	h.Title = parseTitle(h.Raw[0x0134-HeaderStart : 0x0144-HeaderStart])
+	switch h.Raw[0x0143-HeaderStart] {
+	case 0x80:
+		h.CGB = CGBCompatible
+	case 0xc0:
+		h.CGB = CGBOnly
+	}
+	if h.Raw[0x0146-HeaderStart] == 0x03 {
+		h.SGB = SGBSupported
+	}
+	h.NewLicensee = string(h.Raw[0x0144-HeaderStart : 0x0146-HeaderStart])
+	h.OldLicensee = h.Raw[0x014b-HeaderStart]
+	h.CartridgeType = h.Raw[0x0147-HeaderStart]
+	h.ROMSize = h.Raw[0x0148-HeaderStart]
+	h.RAMSize = h.Raw[0x0149-HeaderStart]
+	h.Version = h.Raw[0x014c-HeaderStart]
+	h.HeaderChecksum = h.Raw[0x014d-HeaderStart]
+	h.GlobalChecksum = binary.BigEndian.Uint16(h.Raw[0x014e-HeaderStart:])
+	return h, nil
+}
+
+/*
+ * The title runs up to the first NUL. On CGB cartridges its last byte is the
+ * CGB flag, which is not part of the title.
+ */
+func parseTitle(field []uint8) string {
+	if last := field[len(field)-1]; last == 0x80 || last == 0xc0 {
+		field = field[:len(field)-1]
+	}
+	for n, c := range field {
+		if c == 0x00 {
+			return string(field[:n])
+		}
+	}
+	return string(field)
+}
+
+/* Returns the name of the cartridge's MBC and extra hardware */
+func (h *CartHeader) CartridgeTypeName() string {
+	if name, ok := cartridgeTypes[h.CartridgeType]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (0x%02x)", h.CartridgeType)
+}
+
+/* Returns the size of the cartridge RAM in bytes, -1 if the code is unknown */
+func (h *CartHeader) RAMBytes() int {
+	switch h.RAMSize {
+	case 0x00:
+		return 0
+	case 0x01:
+		return 0x800
+	case 0x02:
+		return 0x2000
+	case 0x03:
+		return 0x8000
+	case 0x04:
+		return 0x20000
+	case 0x05:
+		return 0x10000
+	}
+	return -1
+}
+
+/* The checksum the boot ROM computes over 0x0134-0x014C */
+func (h *CartHeader) ComputeHeaderChecksum() uint8 {
+	var x uint8
+	for _, b := range h.Raw[0x0134-HeaderStart : 0x014d-HeaderStart] {
+		x = x - b - 1
+	}
+	return x
+}
+
+/* Reports whether the header checksum matches; real hardware refuses to boot otherwise */
+func (h *CartHeader) HeaderChecksumOK() bool {
+	return h.ComputeHeaderChecksum() == h.HeaderChecksum
+}
+
+/* Reports whether the header holds the Nintendo logo; real hardware refuses to boot otherwise */
+func (h *CartHeader) LogoOK() bool {
+	return bytes.Equal(h.Raw[LogoStart-HeaderStart:LogoEnd-HeaderStart], NintendoLogo[:])
+}
+
+/* The 16-bit sum of every byte of rom except the two global checksum bytes at 0x014E-0x014F */
+func ComputeGlobalChecksum(rom []uint8) uint16 {
+	var sum uint16
+	for n, b := range rom {
+		if n != 0x014e && n != 0x014f {
+			sum += uint16(b)
+		}
+	}
+	return sum
+}
+
+/*
+ * Checks both checksums of a whole ROM image. Only the header checksum is
+ * verified by real hardware; the global checksum is informational.
+ */
+func VerifyChecksums(rom []uint8) (headerOK bool, globalOK bool, err error) {
+	h, err := ParseHeader(bytes.NewReader(rom))
+	if err != nil {
+		return false, false, err
+	}
+	return h.HeaderChecksumOK(), ComputeGlobalChecksum(rom) == h.GlobalChecksum, nil
+}
+
+/* Returns the number of 16KB ROM banks described by the ROM size code, 0 if unknown */
+func (h *CartHeader) ROMBanks() int {
+	switch h.ROMSize {
+	case 0x52:
+		return 72
+	case 0x53:
+		return 80
+	case 0x54:
+		return 96
+	}
+	if h.ROMSize <= 0x08 {
+		return 2 << h.ROMSize
+	}
+	return 0
+}
+
+type BankRange struct {
+	Bank       uint16
+	Start, End uint32
+}
+
+/* Returns the file offset range [Start, End) of every ROM bank, starting with bank 0 */
+func BankRanges(header *CartHeader) []BankRange {
+	banks := header.ROMBanks()
+	ranges := make([]BankRange, banks)
+	for bank := 0; bank < banks; bank++ {
+		ranges[bank] = BankRange{
+			Bank:  uint16(bank),
+			Start: uint32(bank) * ROMBankSize,
+			End:   uint32(bank+1) * ROMBankSize,
+		}
+	}
+	return ranges
+}
+
+/*
+ * Returns the bytes of ROM bank bank and the address they are mapped at: bank
+ * 0 is fixed at 0x0000-0x3FFF, every other bank is switched into 0x4000-0x7FFF.
+ * A short final bank is returned as is.
+ */
+func bankWindow(data []uint8, bank int) ([]uint8, uint32, error) {
+	start := bank * ROMBankSize
+	if bank < 0 || start >= len(data) {
+		return nil, 0, fmt.Errorf("bank %d out of range", bank)
+	}
+	end := start + ROMBankSize
+	if end > len(data) {
+		end = len(data)
+	}
+	var base uint32 = ROMBankSize
+	if bank == 0 {
+		base = 0
+	}
+	return data[start:end], base, nil
+}
+
+/*
+ * Returns the file offset of the byte at address addr while ROM bank bank is
+ * switched in; addresses outside the switchable window, or with bank 0, are
+ * their own offsets
+ */
+func romOffset(addr uint32, bank uint16) uint32 {
+	if addr >= ROMBankSize && addr < 2*ROMBankSize && bank > 0 {
+		return uint32(bank)*ROMBankSize + addr - ROMBankSize
+	}
+	return addr
+}
+
+/* Disassembles a whole ROM bank at the addresses it is mapped at */
+func DisassembleBank(data []uint8, bank int) ([]*GBInstruction, error) {
+	window, base, err := bankWindow(data, bank)
+	if err != nil {
+		return nil, err
+	}
+	return Disassemble(bytes.NewReader(window), base, base+uint32(len(window)))
+}
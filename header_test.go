@@ -0,0 +1,238 @@
+package gobjdump
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestBankRanges(t *testing.T) {
+	tests := []struct {
+		romSize uint8
+		banks   int
+	}{
+		{0x00, 2},
+		{0x01, 4},
+		{0x05, 64},
+		{0x08, 512},
+		{0x52, 72},
+		{0x09, 0},
+	}
+	for _, tt := range tests {
+		ranges := BankRanges(&CartHeader{ROMSize: tt.romSize})
+		if len(ranges) != tt.banks {
+			t.Errorf("ROM size 0x%02x: %d banks, want %d", tt.romSize, len(ranges), tt.banks)
+			continue
+		}
+		for n, r := range ranges {
+			want := BankRange{Bank: uint16(n), Start: uint32(n) * 0x4000, End: uint32(n+1) * 0x4000}
+			if r != want {
+				t.Errorf("ROM size 0x%02x: range %d = %+v, want %+v", tt.romSize, n, r, want)
+			}
+		}
+	}
+}
+
+func TestBankRangesTwoBanks(t *testing.T) {
+	want := []BankRange{{0, 0x0000, 0x4000}, {1, 0x4000, 0x8000}}
+	if got := BankRanges(&CartHeader{ROMSize: 0x00}); !slices.Equal(got, want) {
+		t.Errorf("BankRanges = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	rom := make([]uint8, 0x8000)
+	copy(rom[0x0104:], NintendoLogo[:])
+	copy(rom[0x0134:], "TETRIS")
+	rom[0x0147] = 0x03 /* MBC1+RAM+BATTERY */
+	rom[0x0149] = 0x02 /* 8KB */
+	rom[0x014c] = 0x01
+	var sum uint8
+	for _, b := range rom[0x0134:0x014d] {
+		sum = sum - b - 1
+	}
+	rom[0x014e], rom[0x014f] = 0xbe, 0xef
+
+	tests := []struct {
+		name       string
+		checksum   uint8
+		checksumOK bool
+	}{
+		{"correct checksum", sum, true},
+		{"wrong checksum", sum + 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rom[0x014d] = tt.checksum
+			h, err := ParseHeader(bytes.NewReader(rom))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Title != "TETRIS" {
+				t.Errorf("Title = %q, want TETRIS", h.Title)
+			}
+			if h.CartridgeTypeName() != "MBC1+RAM+BATTERY" || h.RAMBytes() != 0x2000 || h.Version != 1 {
+				t.Errorf("type %q RAM %d version %d", h.CartridgeTypeName(), h.RAMBytes(), h.Version)
+			}
+			if h.GlobalChecksum != 0xbeef {
+				t.Errorf("GlobalChecksum = 0x%04x, want 0xbeef", h.GlobalChecksum)
+			}
+			if !h.LogoOK() {
+				t.Error("LogoOK = false")
+			}
+			if h.ComputeHeaderChecksum() != sum {
+				t.Errorf("ComputeHeaderChecksum = 0x%02x, want 0x%02x", h.ComputeHeaderChecksum(), sum)
+			}
+			if h.HeaderChecksumOK() != tt.checksumOK {
+				t.Errorf("HeaderChecksumOK = %v, want %v", !tt.checksumOK, tt.checksumOK)
+			}
+		})
+	}
+}
+
+func TestParseHeaderTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		flag  uint8
+		want  string
+	}{
+		{"full width", "ABCDEFGHIJKLMNO", 'P', "ABCDEFGHIJKLMNOP"},
+		{"nul terminated", "POKEMON RED", 0x00, "POKEMON RED"},
+		{"cgb flag", "ABCDEFGHIJKLMNO", 0x80, "ABCDEFGHIJKLMNO"},
+	}
+	for _, tt := range tests {
+		rom := make([]uint8, 0x0150)
+		copy(rom[0x0134:], tt.field)
+		rom[0x0143] = tt.flag
+		h, err := ParseHeader(bytes.NewReader(rom))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Title != tt.want {
+			t.Errorf("%s: Title = %q, want %q", tt.name, h.Title, tt.want)
+		}
+	}
+}
+
+func TestParseHeaderShort(t *testing.T) {
+	if _, err := ParseHeader(bytes.NewReader(make([]uint8, 0x0140))); err == nil {
+		t.Error("want an error for an image without a full header")
+	}
+}
+
+func TestDisassembleBank(t *testing.T) {
+	rom := make([]uint8, 2*ROMBankSize)
+	copy(rom[0x0000:], []uint8{0x3e, 0x01})
+	/* Bank 1 at file offset 0x4000: call 0x4010; jr -2 */
+	copy(rom[ROMBankSize:], []uint8{0xcd, 0x10, 0x40, 0x18, 0xfe})
+	tests := []struct {
+		bank      int
+		wantFirst string
+		wantLast  uint32
+	}{
+		{0, "0x0000: 3e01         ld     a, 0x01", 0x3fff},
+		{1, "0x4000: cd1040       call   0x4010", 0x7fff},
+	}
+	for _, tt := range tests {
+		insns, err := DisassembleBank(rom, tt.bank)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := insns[0].ToStr(); got != tt.wantFirst {
+			t.Errorf("bank %d: first %q, want %q", tt.bank, got, tt.wantFirst)
+		}
+		if last := insns[len(insns)-1].Addr; last != tt.wantLast {
+			t.Errorf("bank %d: last at 0x%04x, want 0x%04x", tt.bank, last, tt.wantLast)
+		}
+	}
+
+	insns, _ := DisassembleBank(rom, 1)
+	if jr := insns[1]; jr.Addr != 0x4003 || jr.ResolvedTarget == nil || *jr.ResolvedTarget != 0x4003 {
+		t.Errorf("jr -2 decoded as %s, want it at and targeting 0x4003", jr)
+	}
+	if _, err := DisassembleBank(rom, 2); err == nil {
+		t.Error("want an error for a bank past the end of the ROM")
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	rom := make([]uint8, 2*ROMBankSize)
+	copy(rom[0x0134:], "CHECKSUMS")
+	copy(rom[0x0150:], []uint8{0xc3, 0x50, 0x01})
+	var header uint8
+	for _, b := range rom[0x0134:0x014d] {
+		header = header - b - 1
+	}
+	rom[0x014d] = header
+	/* The global checksum covers the header checksum byte too */
+	globalFor := func(rom []uint8) uint16 {
+		var sum uint16
+		for n, b := range rom {
+			if n != 0x014e && n != 0x014f {
+				sum += uint16(b)
+			}
+		}
+		return sum
+	}
+	if got, want := ComputeGlobalChecksum(rom), globalFor(rom); got != want {
+		t.Fatalf("ComputeGlobalChecksum = 0x%04x, want 0x%04x", got, want)
+	}
+
+	tests := []struct {
+		name               string
+		header             uint8
+		globalDelta        uint16
+		headerOK, globalOK bool
+	}{
+		{"both correct", header, 0, true, true},
+		{"wrong header", header ^ 0xff, 0, false, true},
+		{"wrong global", header, 1, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rom[0x014d] = tt.header
+			global := globalFor(rom) + tt.globalDelta
+			rom[0x014e], rom[0x014f] = uint8(global>>8), uint8(global)
+			headerOK, globalOK, err := VerifyChecksums(rom)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if headerOK != tt.headerOK || globalOK != tt.globalOK {
+				t.Errorf("VerifyChecksums = %v, %v; want %v, %v", headerOK, globalOK, tt.headerOK, tt.globalOK)
+			}
+		})
+	}
+
+	if _, _, err := VerifyChecksums(rom[:0x0100]); err == nil {
+		t.Error("want an error for a ROM without a header")
+	}
+}
+
+func TestParseHeaderCGBAndSGB(t *testing.T) {
+	tests := []struct {
+		cgbFlag, sgbFlag uint8
+		wantCGB          CGBSupport
+		wantSGB          SGBSupport
+		wantString       string
+	}{
+		{0x00, 0x00, CGBNone, SGBNone, "DMG"},
+		{0x80, 0x03, CGBCompatible, SGBSupported, "CGB compatible"},
+		{0xc0, 0x00, CGBOnly, SGBNone, "CGB only"},
+		/* Only 0x03 enables SGB functions */
+		{0x00, 0x01, CGBNone, SGBNone, "DMG"},
+	}
+	for _, tt := range tests {
+		rom := make([]uint8, 0x0150)
+		rom[0x0143] = tt.cgbFlag
+		rom[0x0146] = tt.sgbFlag
+		h, err := ParseHeader(bytes.NewReader(rom))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.CGB != tt.wantCGB || h.SGB != tt.wantSGB || h.CGB.String() != tt.wantString {
+			t.Errorf("flags 0x%02x 0x%02x: %v (%d), %v; want %q, %v",
+				tt.cgbFlag, tt.sgbFlag, h.CGB, h.CGB, h.SGB, tt.wantString, tt.wantSGB)
+		}
+	}
+}
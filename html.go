@@ -0,0 +1,118 @@
+package gobjdump
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+func htmlAnchor(addr uint32) string {
+	return fmt.Sprintf("addr-%04x", addr)
+}
+
+/*
+ * Renders instrs as an HTML table. Every row is anchored by its address and
+ * every jump, call and rst whose target is in the listing links to it, shown
+ * by its label when opts.Labels has one as seen from ROM bank opts.Bank.
+ * Labelled addresses get a row of their own. The other options are ignored.
+ */
+func FormatHTML(instrs []*GBInstruction, opts FormatOptions) string {
+	present := make(map[uint32]bool, len(instrs))
+	for _, i := range instrs {
+		present[i.Addr] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("<table class=\"disassembly\">\n")
+	for _, i := range instrs {
+		if label, ok := labelAt(opts.Labels, i.Addr, opts.Bank); ok {
+			fmt.Fprintf(&b, "<tr class=\"label\"><td colspan=\"5\">%s:</td></tr>\n", html.EscapeString(label))
+		}
+		fmt.Fprintf(&b, "<tr id=\"%s\"><td class=\"addr\">0x%04x</td><td class=\"bytes\">%s</td>",
+			htmlAnchor(i.Addr), i.Addr, appendHex(nil, i.Instruction))
+		if i.Err != nil {
+			fmt.Fprintf(&b, "<td class=\"error\" colspan=\"2\">%s</td>", html.EscapeString(i.Err.Error()))
+		} else if len(i.Mnemonic) == 0 {
+			b.WriteString("<td class=\"error\" colspan=\"2\">(bad)</td>")
+		} else {
+			operands := make([]string, len(i.Mnemonic)-1)
+			for n, operand := range i.Mnemonic[1:] {
+				operands[n] = html.EscapeString(operand)
+			}
+			if i.ResolvedTarget != nil && len(operands) > 0 {
+				target := *i.ResolvedTarget
+				n := i.targetOperand()
+				if label, ok := labelAt(opts.Labels, target, opts.Bank); ok {
+					if strings.HasPrefix(operands[n], "[") {
+						operands[n] = "[" + html.EscapeString(label) + "]"
+					} else {
+						operands[n] = html.EscapeString(label)
+					}
+				}
+				if present[target] {
+					operands[n] = fmt.Sprintf("<a href=\"#%s\">%s</a>", htmlAnchor(target), operands[n])
+				}
+			}
+			fmt.Fprintf(&b, "<td class=\"op\">%s</td><td class=\"operands\">%s</td>",
+				html.EscapeString(i.Mnemonic[0]), strings.Join(operands, ", "))
+		}
+		fmt.Fprintf(&b, "<td class=\"comment\">%s</td></tr>\n", html.EscapeString(strings.Join(i.Comments, "; ")))
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+/*
+ * Renders insns as an HTML <pre> block, one instruction per line, with the
+ * parts in spans for styling: "addr", "bytes", "op" plus the Category, e.g.
+ * "op control-flow", "reg", "cond", "imm", "comment", and "error" in place of
+ * the instruction text when it failed to decode. All text is escaped.
+ */
+func ToHTML(insns []*GBInstruction) string {
+	var b strings.Builder
+	b.WriteString("<pre class=\"gobjdump\">\n")
+	for _, i := range insns {
+		fmt.Fprintf(&b, "<span class=\"addr\">0x%04x</span> <span class=\"bytes\">%s</span> ",
+			i.Addr, appendHex(nil, i.Instruction))
+		if i.Err != nil {
+			fmt.Fprintf(&b, "<span class=\"error\">%s</span>", html.EscapeString(i.Err.Error()))
+		} else {
+			fmt.Fprintf(&b, "<span class=\"op %s\">%s</span>", categoryClass(i.Category), html.EscapeString(i.Op()))
+			for n, operand := range i.Operands() {
+				sep := ","
+				if n == 0 {
+					sep = ""
+				}
+				fmt.Fprintf(&b, "%s <span class=\"%s\">%s</span>", sep, operandClass(operand), html.EscapeString(operand))
+			}
+		}
+		for _, comment := range i.Comments {
+			fmt.Fprintf(&b, " <span class=\"comment\">; %s</span>", html.EscapeString(comment))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("</pre>\n")
+	return b.String()
+}
+
+/* Returns c as a CSS class name, e.g. "rotate-shift" */
+func categoryClass(c Category) string {
+	return strings.NewReplacer(" ", "-", "/", "-").Replace(c.String())
+}
+
+/*
+ * Classifies an operand token: a condition, including the Z80's parity and
+ * sign ones, a register or memory through one, or an immediate
+ */
+func operandClass(operand string) string {
+	for _, cond := range z80Conditions {
+		if operand == cond {
+			return "cond"
+		}
+	}
+	inner := strings.TrimPrefix(operand, "[")
+	if inner != "" && (inner[0] >= 'a' && inner[0] <= 'z' || inner[0] >= 'A' && inner[0] <= 'Z') {
+		return "reg"
+	}
+	return "imm"
+}
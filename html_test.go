@@ -0,0 +1,131 @@
+package gobjdump
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormatHTMLAnchorsAndLinks(t *testing.T) {
+	/* 0x0150: jp 0x0153; 0x0153: jr 0x0150 */
+	insns, err := Disassemble(bytes.NewReader([]uint8{0xc3, 0x53, 0x01, 0x18, 0xfb}), 0x0150, 0x0155)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := FormatHTML(insns, FormatOptions{Labels: map[uint32]string{0x0150: "Main<1>"}})
+	for _, want := range []string{
+		`<tr class="label"><td colspan="5">Main&lt;1&gt;:</td></tr>`,
+		`<tr id="addr-0150">`,
+		`<tr id="addr-0153">`,
+		`<a href="#addr-0153">0x0153</a>`,
+		`<a href="#addr-0150">Main&lt;1&gt;</a>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("FormatHTML output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+/* One row per instruction: targets link only when they are in the listing, and show their label when they have one */
+func TestFormatHTMLRows(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		want string
+	}{
+		{"target outside the listing", []uint8{0xcd, 0x00, 0x02},
+			`<td class="op">call</td><td class="operands">0x0200</td>`},
+		{"conditional call to itself", []uint8{0xc4, 0x50, 0x01},
+			`<td class="op">call</td><td class="operands">NZ, <a href="#addr-0150">0x0150</a></td>`},
+		{"labelled rst outside the listing", []uint8{0xff},
+			`<td class="op">rst</td><td class="operands">Fill</td>`},
+		{"no target", []uint8{0x3e, 0x12},
+			`<td class="op">ld</td><td class="operands">a, 0x12</td>`},
+		{"illegal", []uint8{0xd3},
+			`<td class="error" colspan="2">Illegal Instruction</td>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, _ := Disassemble(bytes.NewReader(tt.data), 0x0150, 0x0150+uint32(len(tt.data)))
+			insns[0].Comments = []string{"a<b"}
+			want := "<table class=\"disassembly\">\n" +
+				`<tr id="addr-0150"><td class="addr">0x0150</td><td class="bytes">` + fmt.Sprintf("%x", tt.data) + "</td>" +
+				tt.want + `<td class="comment">a&lt;b</td></tr>` + "\n" +
+				"</table>\n"
+			if got := FormatHTML(insns, FormatOptions{Labels: map[uint32]string{0x0038: "Fill"}}); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+/* Labels keyed by bank apply only when rendering that bank */
+func TestFormatHTMLBankedLabels(t *testing.T) {
+	/* 0x4000: jp 0x4000 */
+	insns, err := Disassemble(bytes.NewReader([]uint8{0xc3, 0x00, 0x40}), 0x4000, 0x4003)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := map[uint32]string{SymbolKey(2, 0x4000): "BankTwo"}
+	for _, tt := range []struct {
+		bank     uint16
+		labelled bool
+	}{{2, true}, {3, false}} {
+		out := FormatHTML(insns, FormatOptions{Labels: labels, Bank: tt.bank})
+		for _, want := range []string{
+			`<tr class="label"><td colspan="5">BankTwo:</td></tr>`,
+			`<a href="#addr-4000">BankTwo</a>`,
+		} {
+			if strings.Contains(out, want) != tt.labelled {
+				t.Errorf("bank %d: output has %q: %v, want %v:\n%s", tt.bank, want, !tt.labelled, tt.labelled, out)
+			}
+		}
+	}
+}
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []uint8
+		target CPU
+		want   []string
+	}{
+		{"jp", []uint8{0xc3, 0x50, 0x01}, TargetSM83, []string{
+			`<span class="op control-flow">jp</span> <span class="imm">0x0150</span>`,
+		}},
+		{"ld", []uint8{0x7e}, TargetSM83, []string{
+			`<span class="op load">ld</span> <span class="reg">a</span>, <span class="reg">[hl]</span>`,
+		}},
+		{"jr cc", []uint8{0x38, 0xfe}, TargetSM83, []string{
+			`<span class="cond">C</span>, <span class="imm">-2</span>`,
+		}},
+		{"illegal", []uint8{0xd3}, TargetSM83, []string{
+			`<span class="error">Illegal Instruction</span>`,
+		}},
+		{"z80 jp po", []uint8{0xe2, 0x00, 0x40}, TargetZ80, []string{`<span class="cond">PO</span>`}},
+		{"z80 ret pe", []uint8{0xe8}, TargetZ80, []string{`<span class="cond">PE</span>`}},
+		{"z80 call p", []uint8{0xf4, 0x00, 0x40}, TargetZ80, []string{`<span class="cond">P</span>`}},
+		{"z80 ret m", []uint8{0xf8}, TargetZ80, []string{`<span class="cond">M</span>`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstructionFor(bytes.NewReader(tt.data), 0x0100, tt.target)
+			out := ToHTML([]*GBInstruction{i})
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("ToHTML lacks %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestToHTMLEscapes(t *testing.T) {
+	i, _ := DecodeInstruction(bytes.NewReader([]uint8{0x00}), 0x0100)
+	i.Comments = append(i.Comments, `a < b & "c"`)
+	out := ToHTML([]*GBInstruction{i})
+	if !strings.Contains(out, `<span class="comment">; a &lt; b &amp; &#34;c&#34;</span>`) {
+		t.Errorf("comment not escaped:\n%s", out)
+	}
+}
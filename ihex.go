@@ -0,0 +1,113 @@
+package gobjdump
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ihexData                  = 0x00
+	ihexEOF                   = 0x01
+	ihexExtendedLinearAddress = 0x04
+)
+
+type ihexSegment struct {
+	addr uint32
+	data []uint8
+}
+
+/*
+ * Parses an Intel HEX image. Returns its bytes as one flat buffer starting at
+ * the lowest address any data record writes, and that address. Gaps between
+ * records are filled with 0xff, as in an erased ROM.
+ */
+func LoadIHEX(r io.Reader) ([]uint8, uint32, error) {
+	var segments []ihexSegment
+	var upper uint32
+	sawEOF := false
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		record, err := parseIHEXRecord(text)
+		if err != nil {
+			return nil, 0, fmt.Errorf("ihex line %d: %w", line, err)
+		}
+		count := record[0]
+		addr := uint32(record[1])<<8 | uint32(record[2])
+		data := record[4 : 4+count]
+		switch record[3] {
+		case ihexData:
+			segments = append(segments, ihexSegment{addr: upper | addr, data: data})
+		case ihexEOF:
+			sawEOF = true
+		case ihexExtendedLinearAddress:
+			if count != 2 {
+				return nil, 0, fmt.Errorf("ihex line %d: extended linear address record has %d data bytes, want 2", line, count)
+			}
+			upper = (uint32(data[0])<<8 | uint32(data[1])) << 16
+		default:
+			return nil, 0, fmt.Errorf("ihex line %d: unsupported record type 0x%02x", line, record[3])
+		}
+		if sawEOF {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	if !sawEOF {
+		return nil, 0, fmt.Errorf("ihex: missing end of file record")
+	}
+	if len(segments) == 0 {
+		return nil, 0, nil
+	}
+
+	low, high := segments[0].addr, segments[0].addr
+	for _, s := range segments {
+		if s.addr < low {
+			low = s.addr
+		}
+		if end := s.addr + uint32(len(s.data)); end > high {
+			high = end
+		}
+	}
+	image := make([]uint8, high-low)
+	for n := range image {
+		image[n] = 0xff
+	}
+	for _, s := range segments {
+		copy(image[s.addr-low:], s.data)
+	}
+	return image, low, nil
+}
+
+/* Decodes one ":"-prefixed record and checks its length and checksum */
+func parseIHEXRecord(text string) ([]uint8, error) {
+	if !strings.HasPrefix(text, ":") {
+		return nil, fmt.Errorf("record does not start with ':'")
+	}
+	record, err := hex.DecodeString(text[1:])
+	if err != nil {
+		return nil, fmt.Errorf("bad hex: %w", err)
+	}
+	/* count, 16-bit address, type, data, checksum */
+	if len(record) < 5 || len(record) != 5+int(record[0]) {
+		return nil, fmt.Errorf("record length %d does not match its byte count", len(record))
+	}
+	var sum uint8
+	for _, b := range record {
+		sum += b
+	}
+	if sum != 0 {
+		want := record[len(record)-1] - sum
+		return nil, fmt.Errorf("checksum 0x%02x, want 0x%02x", record[len(record)-1], want)
+	}
+	return record, nil
+}
@@ -0,0 +1,63 @@
+package gobjdump
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLoadIHEX(t *testing.T) {
+	/* ld a, 0x12; ret at 0x0150, jr -2 at 0x0158 */
+	file := ":030150003E12C993\n" +
+		":0201580018FE8F\n" +
+		":00000001FF\n"
+	image, base, err := LoadIHEX(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint8{0x3e, 0x12, 0xc9, 0xff, 0xff, 0xff, 0xff, 0xff, 0x18, 0xfe}
+	if base != 0x0150 || !bytes.Equal(image, want) {
+		t.Fatalf("LoadIHEX = % x at 0x%04x, want % x at 0x0150", image, base, want)
+	}
+	insns, err := Disassemble(bytes.NewReader(image), base, base+uint32(len(image)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := insns[len(insns)-1].ToStr(); got != "0x0158: 18fe         jr     -2" {
+		t.Errorf("last instruction %q", got)
+	}
+}
+
+func TestLoadIHEXExtendedAddress(t *testing.T) {
+	file := ":020000040001F9\n:01001000AA45\n:00000001FF\n"
+	image, base, err := LoadIHEX(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base != 0x00010010 || !bytes.Equal(image, []uint8{0xaa}) {
+		t.Errorf("LoadIHEX = % x at 0x%x, want aa at 0x10010", image, base)
+	}
+}
+
+func TestLoadIHEXErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"corrupted checksum", ":030150003E12C994\n:00000001FF\n", "line 1: checksum 0x94, want 0x93"},
+		{"no colon", "030150003E12C993\n:00000001FF\n", "line 1: record does not start"},
+		{"bad hex", ":0301500G3E12C993\n", "line 1: bad hex"},
+		{"short record", ":0201580018FE\n:00000001FF\n", "line 1: record length"},
+		{"record type", ":00000005FB\n", "unsupported record type 0x05"},
+		{"missing eof", ":030150003E12C993\n", "missing end of file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := LoadIHEX(strings.NewReader(tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFormatIORegisters(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []uint8
+		target CPU
+		rgbds  bool
+		want   string
+	}{
+		{"ldh store", []uint8{0xe0, 0x40}, TargetSM83, false, "0x0150: e040         ld     [rLCDC], a"},
+		{"ldh load", []uint8{0xf0, 0x44}, TargetSM83, false, "0x0150: f044         ld     a, [rLY]"},
+		{"ldh rgbds", []uint8{0xe0, 0x40}, TargetSM83, true, "0x0150: e040         ldh    [rLCDC], a"},
+		{"ld [nn] store", []uint8{0xea, 0xff, 0xff}, TargetSM83, false, "0x0150: eaffff       ld     [rIE], a"},
+		{"ld [nn] load", []uint8{0xfa, 0x00, 0xff}, TargetSM83, false, "0x0150: fa00ff       ld     a, [rP1]"},
+		{"high RAM", []uint8{0xe0, 0x80}, TargetSM83, false, "0x0150: e080         ld     [0xff80], a"},
+		{"work RAM", []uint8{0xea, 0x00, 0xc0}, TargetSM83, false, "0x0150: ea00c0       ld     [0xc000], a"},
+		{"through c", []uint8{0xe2}, TargetSM83, false, "0x0150: e2           ld     [0xff00 + C], a"},
+		{"z80 ret po", []uint8{0xe0}, TargetZ80, false, "0x0150: e0           ret    PO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstructionFor(bytes.NewReader(tt.data), 0x0150, tt.target)
+			if got := i.ToStrWithOptions(FormatOptions{IORegisters: true, RGBDS: tt.rgbds}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
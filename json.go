@@ -0,0 +1,29 @@
+package gobjdump
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type jsonInstruction struct {
+	Addr     string   `json:"addr"`
+	Bytes    string   `json:"bytes"`
+	Opcode   string   `json:"opcode,omitempty"`
+	Operands []string `json:"operands"`
+	Error    string   `json:"error,omitempty"`
+}
+
+/* Serializes i without its Prev/Next links, which would otherwise form a cycle */
+func (i *GBInstruction) MarshalJSON() ([]byte, error) {
+	j := jsonInstruction{
+		Addr:     fmt.Sprintf("0x%04x", i.Addr),
+		Bytes:    string(appendHex(nil, i.Instruction)),
+		Operands: []string{},
+	}
+	if i.Err != nil {
+		j.Error = i.Err.Error()
+	}
+	j.Opcode = i.Op()
+	j.Operands = append(j.Operands, i.Operands()...)
+	return json.Marshal(&j)
+}
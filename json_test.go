@@ -0,0 +1,58 @@
+package gobjdump
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		want map[string]any
+	}{
+		{"two operands", []uint8{0x01, 0x34, 0x12}, map[string]any{
+			"addr": "0x0150", "bytes": "013412", "opcode": "ld", "operands": []any{"bc", "0x1234"},
+		}},
+		{"no operands", []uint8{0x00}, map[string]any{
+			"addr": "0x0150", "bytes": "00", "opcode": "nop", "operands": []any{},
+		}},
+		{"error", []uint8{0xd3}, map[string]any{
+			"addr": "0x0150", "bytes": "d3", "operands": []any{}, "error": "Illegal Instruction",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			b, err := json.Marshal(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]any
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %s, want %v", b, tt.want)
+			}
+		})
+	}
+}
+
+/* Linked instructions point at each other; marshalling must not follow the links */
+func TestMarshalJSONLinked(t *testing.T) {
+	insns, _ := Disassemble(bytes.NewReader([]uint8{0x00, 0x3c, 0xc9}), 0x0150, 0x0153)
+	b, err := json.Marshal(insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[2]["addr"] != "0x0152" {
+		t.Errorf("got %s", b)
+	}
+}
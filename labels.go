@@ -0,0 +1,197 @@
+package gobjdump
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/* A label name bound to more than one address */
+type LabelConflict struct {
+	Name  string
+	Addrs []uint32
+}
+
+func (c *LabelConflict) String() string {
+	addrs := make([]string, len(c.Addrs))
+	for n, addr := range c.Addrs {
+		addrs[n] = fmt.Sprintf("0x%04x", addr)
+	}
+	return fmt.Sprintf("label %s assigned to %s", c.Name, strings.Join(addrs, ", "))
+}
+
+/*
+ * Inverts labels and reports every name that is assigned to more than one
+ * address. An address can only carry one name in the map, so name collisions
+ * are the only duplicates left to find. Conflicts are sorted by name and
+ * their addresses in ascending order.
+ */
+func ValidateLabels(labels map[uint32]string) []LabelConflict {
+	byName := make(map[string][]uint32)
+	for addr, name := range labels {
+		byName[name] = append(byName[name], addr)
+	}
+
+	var conflicts []LabelConflict
+	for name, addrs := range byName {
+		if len(addrs) < 2 {
+			continue
+		}
+		sort.Slice(addrs, func(a, b int) bool { return addrs[a] < addrs[b] })
+		conflicts = append(conflicts, LabelConflict{Name: name, Addrs: addrs})
+	}
+	sort.Slice(conflicts, func(a, b int) bool { return conflicts[a].Name < conflicts[b].Name })
+	return conflicts
+}
+
+/*
+ * Assigns an "L_xxxx" label to every resolved branch target in insns that
+ * falls on an instruction boundary. Targets in the middle of an instruction,
+ * or outside insns, are left to be printed as raw addresses.
+ */
+func GenerateLabels(insns []*GBInstruction) map[uint32]string {
+	boundaries := make(map[uint32]bool, len(insns))
+	for _, i := range insns {
+		boundaries[i.Addr] = true
+	}
+	labels := make(map[uint32]string)
+	for _, i := range insns {
+		if target, ok := branchTarget(i); ok && boundaries[target] {
+			labels[target] = fmt.Sprintf("L_%04x", target)
+		}
+	}
+	return labels
+}
+
+/*
+ * Returns the labels for insns: symbols over fixed names over generated
+ * labels, when generate is set. nil if there are none.
+ */
+func mergeLabels(insns []*GBInstruction, generate bool, fixed map[uint32]string, symbols map[uint32]string) map[uint32]string {
+	var labels map[uint32]string
+	if generate {
+		labels = GenerateLabels(insns)
+	}
+	if len(symbols)+len(fixed) > 0 && labels == nil {
+		labels = make(map[uint32]string, len(symbols)+len(fixed))
+	}
+	for addr, name := range fixed {
+		labels[addr] = name
+	}
+	for addr, name := range symbols {
+		labels[addr] = name
+	}
+	return labels
+}
+
+/*
+ * Writes insns one per line with Format, each labelled address preceded by a
+ * "label:" line, and blank lines between blocks under BlockSeparators.
+ * Under CollapseFill a run of fill bytes is listed as one line at its address.
+ */
+func (d *Disassembler) WriteListing(w io.Writer, insns []*GBInstruction) error {
+	return d.listing(insns, func(line string, i *GBInstruction) error {
+		_, err := fmt.Fprintf(w, "%s\n", line)
+		return err
+	})
+}
+
+/* Where a line of a listing points in the ROM */
+type SourceMapping struct {
+	Addr uint32
+	/*
+	 * The file offset of Addr: the one its Provenance records, else its place
+	 * in ROM bank Bank when Addr is in the switchable window, else Addr itself
+	 */
+	Offset uint32
+}
+
+/*
+ * Returns the lines WriteListing would write for insns, without newlines,
+ * and the address each one belongs to. Label lines, and blank lines between
+ * blocks, belong to the instruction that follows them.
+ */
+func (d *Disassembler) ListingWithSourceMap(insns []*GBInstruction) ([]string, []SourceMapping) {
+	var lines []string
+	var mappings []SourceMapping
+	d.listing(insns, func(line string, i *GBInstruction) error {
+		lines = append(lines, line)
+		mappings = append(mappings, SourceMapping{Addr: i.Addr, Offset: fileOffset(i, &d.Options)})
+		return nil
+	})
+	return lines, mappings
+}
+
+/* Returns the ROM file offset of i, see SourceMapping */
+func fileOffset(i *GBInstruction, opts *FormatOptions) uint32 {
+	if i.Provenance != nil {
+		return i.Provenance.FileOffset
+	}
+	return romOffset(i.Addr, opts.Bank)
+}
+
+/* Calls emit with every line of the listing of insns and the instruction it belongs to */
+func (d *Disassembler) listing(insns []*GBInstruction, emit func(line string, i *GBInstruction) error) error {
+	var targets map[uint32]bool
+	if d.Options.BlockSeparators {
+		targets = make(map[uint32]bool)
+		for _, i := range insns {
+			if target, ok := branchTarget(i); ok {
+				targets[target] = true
+			}
+		}
+	}
+	for n := 0; n < len(insns); n++ {
+		i := insns[n]
+		if n > 0 && d.Options.BlockSeparators && (!insns[n-1].Flow.FallsThrough() || targets[i.Addr]) {
+			if err := emit("", i); err != nil {
+				return err
+			}
+		}
+		if label, ok := labelAt(d.Options.Labels, i.Addr, d.Options.Bank); ok {
+			if err := emit(label+":", i); err != nil {
+				return err
+			}
+		}
+		if run := d.fillRun(insns[n:]); run > 0 {
+			addr := appendAddr(nil, i.Addr, &d.Options)
+			if err := emit(fmt.Sprintf("%s: ... 0x%02x x %d ...", addr, i.Instruction[0], run), i); err != nil {
+				return err
+			}
+			n += run - 1
+			continue
+		}
+		if err := emit(d.Format(i), i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * Returns the length of the run of identical one-byte entries at the start of
+ * insns, if at least CollapseFill long, else 0. A run ends at a gap in the
+ * addresses and at a label, so nothing named is hidden.
+ */
+func (d *Disassembler) fillRun(insns []*GBInstruction) int {
+	if d.Options.CollapseFill <= 0 || len(insns[0].Instruction) != 1 {
+		return 0
+	}
+	fill := insns[0].Instruction[0]
+	run := 1
+	for run < len(insns) {
+		i := insns[run]
+		if len(i.Instruction) != 1 || i.Instruction[0] != fill || i.Addr != insns[run-1].Addr+1 {
+			break
+		}
+		if _, ok := labelAt(d.Options.Labels, i.Addr, d.Options.Bank); ok {
+			break
+		}
+		run++
+	}
+	if run < d.Options.CollapseFill {
+		return 0
+	}
+	return run
+}
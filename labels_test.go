@@ -0,0 +1,237 @@
+package gobjdump
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[uint32]string
+		want   []LabelConflict
+	}{
+		{"none", nil, nil},
+		{"unique", map[uint32]string{0x0150: "Main", 0x0200: "Loop"}, nil},
+		{"duplicate", map[uint32]string{0x0200: "Loop", 0x0150: "Loop", 0x0300: "Main"},
+			[]LabelConflict{{"Loop", []uint32{0x0150, 0x0200}}}},
+		{"sorted by name", map[uint32]string{0x0400: "B", 0x0300: "B", 0x0200: "A", 0x0100: "A", 0x0500: "A"},
+			[]LabelConflict{{"A", []uint32{0x0100, 0x0200, 0x0500}}, {"B", []uint32{0x0300, 0x0400}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateLabels(tt.labels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateLabels = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelConflictString(t *testing.T) {
+	c := LabelConflict{Name: "Loop", Addrs: []uint32{0x0150, 0x0200}}
+	if got, want := c.String(), "label Loop assigned to 0x0150, 0x0200"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateLabels(t *testing.T) {
+	program := []uint8{
+		0x3e, 0x12, /* 0x0150: ld a, 0x12 */
+		0x20, 0xfc, /* 0x0152: jr nz, 0x0150 */
+		0xc2, 0x50, 0x01, /* 0x0154: jp nz, 0x0150 */
+		0x18, 0xf8, /* 0x0157: jr 0x0151, inside ld a, 0x12 */
+		0xcd, 0x00, 0x02, /* 0x0159: call 0x0200, outside the slice */
+	}
+	insns, err := Disassemble(bytes.NewReader(program), 0x0150, 0x0150+uint32(len(program)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := GenerateLabels(insns)
+	if want := map[uint32]string{0x0150: "L_0150"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("GenerateLabels = %v, want %v", labels, want)
+	}
+
+	d := &Disassembler{Options: FormatOptions{Labels: labels}}
+	var buf bytes.Buffer
+	d.WriteListing(&buf, insns)
+	want := "L_0150:\n" +
+		"0x0150: 3e12         ld     a, 0x12\n" +
+		"0x0152: 20fc         jr     NZ, L_0150\n" +
+		"0x0154: c25001       jp     NZ, L_0150\n" +
+		"0x0157: 18f8         jr     -8\n" +
+		"0x0159: cd0002       call   0x0200\n"
+	if buf.String() != want {
+		t.Errorf("listing:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestBlockSeparators(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []uint8
+		separators bool
+		want       string
+	}{
+		{"after ret and jp", []uint8{0x3e, 0x01, 0xc9, 0x00, 0xc3, 0x00, 0x02, 0x00}, true,
+			"0x0150: 3e01         ld     a, 0x01\n" +
+				"0x0152: c9           ret\n" +
+				"\n" +
+				"0x0153: 00           nop\n" +
+				"0x0154: c30002       jp     0x0200\n" +
+				"\n" +
+				"0x0157: 00           nop\n"},
+		{"before a branch target", []uint8{0xaf, 0x3d, 0x20, 0xfd, 0xc9}, true,
+			"0x0150: af           xor    a\n" +
+				"\n" +
+				"0x0151: 3d           dec    a\n" +
+				"0x0152: 20fd         jr     NZ, -3\n" +
+				"0x0154: c9           ret\n"},
+		{"off", []uint8{0x3e, 0x01, 0xc9, 0x00}, false,
+			"0x0150: 3e01         ld     a, 0x01\n" +
+				"0x0152: c9           ret\n" +
+				"0x0153: 00           nop\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, _ := Disassemble(bytes.NewReader(tt.data), 0x0150, 0x0150+uint32(len(tt.data)))
+			d := &Disassembler{Options: FormatOptions{BlockSeparators: tt.separators}}
+			var buf bytes.Buffer
+			if err := d.WriteListing(&buf, insns); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("listing:\n%s\nwant:\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+/* A branch target after a ret gets one blank line, before its label */
+func TestBlockSeparatorsLabel(t *testing.T) {
+	insns, _ := Disassemble(bytes.NewReader([]uint8{0xc9, 0xaf, 0x20, 0xfd}), 0x0150, 0x0154)
+	d := &Disassembler{Options: FormatOptions{BlockSeparators: true, Labels: map[uint32]string{0x0151: "Loop"}}}
+	var buf bytes.Buffer
+	d.WriteListing(&buf, insns)
+	want := "0x0150: c9           ret\n" +
+		"\n" +
+		"Loop:\n" +
+		"0x0151: af           xor    a\n" +
+		"0x0152: 20fd         jr     NZ, Loop\n"
+	if buf.String() != want {
+		t.Errorf("listing:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestCollapseFill(t *testing.T) {
+	ff := func(n int) []uint8 { return bytes.Repeat([]uint8{0xff}, n) }
+	tests := []struct {
+		name     string
+		data     []uint8
+		collapse int
+		labels   map[uint32]string
+		want     string
+	}{
+		{"100 bytes of 0xff", append([]uint8{0xc9}, ff(100)...), 16, nil,
+			"0x0150: c9           ret\n" +
+				"0x0151: ... 0xff x 100 ...\n"},
+		{"code after the fill", append(append([]uint8{0x00}, ff(100)...), 0xc9), 16, nil,
+			"0x0150: 00           nop\n" +
+				"0x0151: ... 0xff x 100 ...\n" +
+				"0x01b5: c9           ret\n"},
+		{"shorter than the threshold", ff(3), 4, nil,
+			"0x0150: ff           rst    0x38\n" +
+				"0x0151: ff           rst    0x38\n" +
+				"0x0152: ff           rst    0x38\n"},
+		{"split at a label", ff(8), 4, map[uint32]string{0x0154: "Handler"},
+			"0x0150: ... 0xff x 4 ...\n" +
+				"Handler:\n" +
+				"0x0154: ... 0xff x 4 ...\n"},
+		{"multi-byte instructions are not fill", bytes.Repeat([]uint8{0x3e, 0x3e}, 4), 2, nil,
+			"0x0150: 3e3e         ld     a, 0x3e\n" +
+				"0x0152: 3e3e         ld     a, 0x3e\n" +
+				"0x0154: 3e3e         ld     a, 0x3e\n" +
+				"0x0156: 3e3e         ld     a, 0x3e\n"},
+		{"off", ff(2), 0, nil,
+			"0x0150: ff           rst    0x38\n" +
+				"0x0151: ff           rst    0x38\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, _ := Disassemble(bytes.NewReader(tt.data), 0x0150, 0x0150+uint32(len(tt.data)))
+			d := &Disassembler{Options: FormatOptions{CollapseFill: tt.collapse, Labels: tt.labels}}
+			var buf bytes.Buffer
+			if err := d.WriteListing(&buf, insns); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("listing:\n%s\nwant:\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestListingWithSourceMap(t *testing.T) {
+	/* ld a, 0x12; jr 0x0150; nop */
+	program := []uint8{0x3e, 0x12, 0x18, 0xfc, 0x00}
+	tests := []struct {
+		name     string
+		opts     FormatOptions
+		lines    []string
+		mappings []SourceMapping
+	}{
+		{"plain", FormatOptions{}, []string{
+			"0x0150: 3e12         ld     a, 0x12",
+			"0x0152: 18fc         jr     -4",
+			"0x0154: 00           nop",
+		}, []SourceMapping{{0x0150, 0x0150}, {0x0152, 0x0152}, {0x0154, 0x0154}}},
+		{"labels and separators", FormatOptions{BlockSeparators: true, Labels: map[uint32]string{0x0150: "Loop"}}, []string{
+			"Loop:",
+			"0x0150: 3e12         ld     a, 0x12",
+			"0x0152: 18fc         jr     Loop",
+			"",
+			"0x0154: 00           nop",
+		}, []SourceMapping{{0x0150, 0x0150}, {0x0150, 0x0150}, {0x0152, 0x0152}, {0x0154, 0x0154}, {0x0154, 0x0154}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, _ := Disassemble(bytes.NewReader(program), 0x0150, 0x0155)
+			d := &Disassembler{Options: tt.opts}
+			lines, mappings := d.ListingWithSourceMap(insns)
+			if !slices.Equal(lines, tt.lines) {
+				t.Errorf("lines:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.lines, "\n"))
+			}
+			if !slices.Equal(mappings, tt.mappings) {
+				t.Errorf("mappings %x, want %x", mappings, tt.mappings)
+			}
+			var buf bytes.Buffer
+			d.WriteListing(&buf, insns)
+			if want := strings.Join(lines, "\n") + "\n"; buf.String() != want {
+				t.Errorf("WriteListing wrote\n%s\nwant the same lines", buf.String())
+			}
+		})
+	}
+}
+
+/* Banked addresses map to their offset in the ROM file, however they are displayed */
+func TestListingWithSourceMapBanked(t *testing.T) {
+	want := []SourceMapping{{0x4000, 0x8000}, {0x4001, 0x8001}}
+	for _, showBank := range []bool{false, true} {
+		insns, _ := Disassemble(bytes.NewReader([]uint8{0x00, 0xc9}), 0x4000, 0x4002)
+		d := &Disassembler{Options: FormatOptions{ShowBank: showBank, Bank: 2}}
+		if _, mappings := d.ListingWithSourceMap(insns); !slices.Equal(mappings, want) {
+			t.Errorf("ShowBank %v: mappings %x, want %x", showBank, mappings, want)
+		}
+
+		/* Provenance knows the bank even when the options do not */
+		for _, i := range insns {
+			i.AttachProvenance(2)
+		}
+		d.Options.Bank = 0
+		if _, mappings := d.ListingWithSourceMap(insns); !slices.Equal(mappings, want) {
+			t.Errorf("ShowBank %v, provenance: mappings %x, want %x", showBank, mappings, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package gobjdump
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type SegmentKind uint8
+
+const (
+	SegmentCode SegmentKind = iota
+	SegmentData
+)
+
+/* A region [Start, End) of a ROM image and how to render it */
+type Segment struct {
+	Start, End uint32
+	Kind       SegmentKind
+}
+
+/*
+ * Parses a layout file, lines like "0150-0500 data" giving a hex address
+ * range, end exclusive, and "code" or "data". Comments start with ';'.
+ * Segments are returned in address order and may not overlap.
+ */
+func LoadLayout(r io.Reader) ([]Segment, error) {
+	var segments []Segment
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if n := strings.IndexByte(text, ';'); n >= 0 {
+			text = text[:n]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("layout line %d: want \"start-end kind\", got %q", line, scanner.Text())
+		}
+		startText, endText, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			return nil, fmt.Errorf("layout line %d: missing '-' in %q", line, fields[0])
+		}
+		start, err := strconv.ParseUint(startText, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("layout line %d: bad start %q", line, startText)
+		}
+		end, err := strconv.ParseUint(endText, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("layout line %d: bad end %q", line, endText)
+		}
+		if end <= start {
+			return nil, fmt.Errorf("layout line %d: empty range %s", line, fields[0])
+		}
+		segment := Segment{Start: uint32(start), End: uint32(end)}
+		switch fields[1] {
+		case "code":
+			segment.Kind = SegmentCode
+		case "data":
+			segment.Kind = SegmentData
+		default:
+			return nil, fmt.Errorf("layout line %d: unknown kind %q", line, fields[1])
+		}
+		segments = append(segments, segment)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(segments, func(a, b int) bool { return segments[a].Start < segments[b].Start })
+	for n := 1; n < len(segments); n++ {
+		if segments[n].Start < segments[n-1].End {
+			return nil, fmt.Errorf("layout: 0x%04x-0x%04x overlaps 0x%04x-0x%04x",
+				segments[n].Start, segments[n].End, segments[n-1].Start, segments[n-1].End)
+		}
+	}
+	return segments, nil
+}
+
+/*
+ * Writes a listing of every segment of rom to w, addresses being file
+ * offsets: code through d, which never reads past the end of a segment, and
+ * data as db lines. Segments running past the end of rom are cut short.
+ * Decoding errors are returned after the whole listing is written.
+ */
+func SegmentedDisassemble(w io.Writer, d *Disassembler, rom []uint8, segments []Segment) error {
+	var firstErr error
+	for _, segment := range segments {
+		end := min(segment.End, uint32(len(rom)))
+		if segment.Start >= end {
+			continue
+		}
+		data := rom[segment.Start:end]
+		if segment.Kind == SegmentData {
+			for _, line := range FormatDataRange(data, segment.Start, dataBytesPerLine) {
+				if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		gbInstructions, err := d.Disassemble(bytes.NewReader(data), segment.Start, end)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := d.WriteListing(w, gbInstructions); err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,88 @@
+package gobjdump
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []Segment
+		wantErr string
+	}{
+		{"sorted, with comments", "; header\n0154-0158 data\n0150-0154 code ; entry\n\n", []Segment{
+			{Start: 0x0150, End: 0x0154, Kind: SegmentCode},
+			{Start: 0x0154, End: 0x0158, Kind: SegmentData},
+		}, ""},
+		{"empty", "", nil, ""},
+		{"missing kind", "0150-0154\n", nil, "layout line 1: want"},
+		{"missing dash", "0150 code\n", nil, "layout line 1: missing '-'"},
+		{"bad start", "01x0-0154 code\n", nil, `layout line 1: bad start "01x0"`},
+		{"bad end", "0150-zz code\n", nil, `layout line 1: bad end "zz"`},
+		{"empty range", "\n0154-0150 code\n", nil, "layout line 2: empty range 0154-0150"},
+		{"unknown kind", "0150-0154 text\n", nil, `layout line 1: unknown kind "text"`},
+		{"overlap", "0150-0158 code\n0154-0160 data\n", nil, "layout: 0x0154-0x0160 overlaps 0x0150-0x0158"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadLayout(strings.NewReader(tt.text))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentedDisassemble(t *testing.T) {
+	rom := make([]uint8, 0x0158)
+	copy(rom[0x0150:], []uint8{0x3e, 0x12, 0x18, 0xfc, 'A', 'B', 0x00, 0xff})
+	tests := []struct {
+		name     string
+		segments []Segment
+		want     string
+		wantErr  bool
+	}{
+		{"code then data", []Segment{
+			{Start: 0x0150, End: 0x0154, Kind: SegmentCode},
+			{Start: 0x0154, End: 0x0158, Kind: SegmentData},
+		}, "0x0150: 3e12         ld     a, 0x12\n" +
+			"0x0152: 18fc         jr     -4\n" +
+			"0x0154: 414200ff     db     0x41, 0x42, 0x00, 0xff\n", false},
+		/* The code segment ends inside jr, whose operand is never read from the data after it */
+		{"code cut short", []Segment{
+			{Start: 0x0150, End: 0x0153, Kind: SegmentCode},
+			{Start: 0x0153, End: 0x0154, Kind: SegmentData},
+		}, "0x0150: 3e12         ld     a, 0x12\n" +
+			"0x0152: 18           Malformed Instruction\n" +
+			"0x0153: fc           db     0xfc\n", true},
+		{"past the end of the ROM", []Segment{
+			{Start: 0x0156, End: 0x0200, Kind: SegmentData},
+			{Start: 0x0300, End: 0x0400, Kind: SegmentCode},
+		}, "0x0156: 00ff         db     0x00, 0xff\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := SegmentedDisassemble(&buf, &Disassembler{}, rom, tt.segments)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("listing:\n%s\nwant:\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package gobjdump
+
+import "bytes"
+
+/*
+ * Built by running every opcode through DecodeInstruction so the tables can
+ * never drift from the decoder. Illegal opcodes are reported as one byte.
+ */
+var lengthTable, lengthTableCB = buildLengthTables()
+
+func buildLengthTables() (base [256]int, cb [256]int) {
+	for op := 0; op < 256; op++ {
+		gbInstruction, _ := DecodeInstruction(bytes.NewReader([]uint8{uint8(op), 0x00, 0x00}), 0)
+		base[op] = len(gbInstruction.Instruction)
+		gbInstruction, _ = DecodeInstruction(bytes.NewReader([]uint8{0xcb, uint8(op)}), 0)
+		cb[op] = len(gbInstruction.Instruction)
+	}
+	return base, cb
+}
+
+/* Returns the byte length of every primary opcode */
+func LengthTable() [256]int {
+	return lengthTable
+}
+
+/* Returns the byte length of every 0xcb-prefixed opcode, prefix included */
+func LengthTableCB() [256]int {
+	return lengthTableCB
+}
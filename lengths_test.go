@@ -0,0 +1,50 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+/* The tables must match what DecodeInstruction consumes, whatever the operand bytes */
+func TestLengthTableMatchesDecoder(t *testing.T) {
+	table, tableCB := LengthTable(), LengthTableCB()
+	for op := 0; op < 256; op++ {
+		_, next := DecodeInstruction(bytes.NewReader([]uint8{uint8(op), 0x34, 0x12}), 0x0150)
+		if got := int(next - 0x0150); got != table[op] {
+			t.Errorf("0x%02x: LengthTable = %d, decoder consumed %d", op, table[op], got)
+		}
+		_, next = DecodeInstruction(bytes.NewReader([]uint8{0xcb, uint8(op)}), 0x0150)
+		if got := int(next - 0x0150); got != tableCB[op] {
+			t.Errorf("cb 0x%02x: LengthTableCB = %d, decoder consumed %d", op, tableCB[op], got)
+		}
+	}
+}
+
+func TestLengthTable(t *testing.T) {
+	table := LengthTable()
+	tests := []struct {
+		op   uint8
+		want int
+	}{
+		{0x00, 1}, /* nop */
+		{0x01, 3}, /* ld bc, nn */
+		{0x06, 2}, /* ld b, n */
+		{0x10, 2}, /* stop */
+		{0x18, 2}, /* jr e */
+		{0xc3, 3}, /* jp nn */
+		{0xcb, 2}, /* prefix */
+		{0xd3, 1}, /* illegal */
+		{0xe0, 2}, /* ldh [n], a */
+		{0xea, 3}, /* ld [nn], a */
+	}
+	for _, tt := range tests {
+		if table[tt.op] != tt.want {
+			t.Errorf("LengthTable[0x%02x] = %d, want %d", tt.op, table[tt.op], tt.want)
+		}
+	}
+	for op, n := range LengthTableCB() {
+		if n != 2 {
+			t.Errorf("LengthTableCB[0x%02x] = %d, want 2", op, n)
+		}
+	}
+}
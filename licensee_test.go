@@ -0,0 +1,36 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLicenseeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldCode  uint8
+		newCode  string
+		wantName string
+	}{
+		{"old code", 0x01, "", "Nintendo"},
+		{"old code ignores new", 0x08, "01", "Capcom"},
+		{"new code", 0x33, "01", "Nintendo R&D1"},
+		{"new code letters", 0x33, "A4", "Konami (Yu-Gi-Oh!)"},
+		{"unknown old code", 0xfe, "", `unknown (0xfe)`},
+		{"unknown new code", 0x33, "ZZ", `unknown ("ZZ")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rom := make([]uint8, 0x0150)
+			copy(rom[0x0144:], tt.newCode)
+			rom[0x014b] = tt.oldCode
+			h, err := ParseHeader(bytes.NewReader(rom))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := h.LicenseeName(); got != tt.wantName {
+				t.Errorf("LicenseeName = %q, want %q", got, tt.wantName)
+			}
+		})
+	}
+}
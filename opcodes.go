@@ -0,0 +1,150 @@
+package gobjdump
+
+import "fmt"
+
+/* Decodes the operands of the opcode already in instruction, reading any immediates from r */
+type decodeFunc func(r Reader, instruction *[]uint8, mnemonic *[]string) error
+
+/* Adapts a decoder that cannot fail */
+func infallible(decode func(Reader, *[]uint8, *[]string)) decodeFunc {
+	return func(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+		decode(r, instruction, mnemonic)
+		return nil
+	}
+}
+
+/* Decodes an opcode with no operands */
+func fixed(op string) decodeFunc {
+	return func(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+		*mnemonic = append(*mnemonic, op)
+		return nil
+	}
+}
+
+func illegal(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	return &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction, illegal: IllegalUnusedOpcode}
+}
+
+func z80Prefix(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	return &Z80AsmError{errorType: Z80AsmErrorIllegalInstruction, illegal: IllegalZ80Prefix}
+}
+
+/*
+ * One decoder per opcode, indexed by the opcode byte. Every slot is filled,
+ * either with a decoder or with illegal, so the map can be audited at a
+ * glance; checkOpcodeTable panics on a hole.
+ */
+var sm83Opcodes = buildOpcodeTable()
+
+/* The 0xcb-prefixed opcodes, indexed by the byte after the prefix */
+var cbOpcodes = func() *[256]decodeFunc {
+	t := new([256]decodeFunc)
+	for op := 0x00; op < 0x40; op++ {
+		t[op] = infallible(decodeRotateShift_r8)
+		t[op|0x40] = infallible(decodeBIT_b_r8)
+		t[op|0x80] = infallible(decodeRES_b_r8)
+		t[op|0xc0] = infallible(decodeSET_b_r8)
+	}
+	return checkOpcodeTable("0xcb", t)
+}()
+
+func buildOpcodeTable() *[256]decodeFunc {
+	t := new([256]decodeFunc)
+
+	t[0x00] = fixed("nop")
+	t[0x08] = decodeLD_nn_SP
+	t[0x10] = decodeSTOP
+	t[0x18] = decodeJR_E
+	t[0x02] = infallible(decodeLD_BC_A)
+	t[0x12] = infallible(decodeLD_DE_A)
+	t[0x22] = infallible(decodeLDI_HL_A)
+	t[0x32] = infallible(decodeLDD_HL_A)
+	t[0x0a] = infallible(decodeLD_A_BC)
+	t[0x1a] = infallible(decodeLD_A_DE)
+	t[0x2a] = infallible(decodeLDI_A_HL)
+	t[0x3a] = infallible(decodeLDD_A_HL)
+	for n, op := range []string{"rlca", "rrca", "rla", "rra", "daa", "cpl", "scf", "ccf"} {
+		t[0x07|n<<3] = fixed(op)
+	}
+
+	/* Opcodes parameterised by a register pair in bits 4-5 */
+	for p := 0; p < 4; p++ {
+		t[0x01|p<<4] = decodeLD_r16_nn
+		t[0x03|p<<4] = infallible(decodeINC_r16)
+		t[0x09|p<<4] = infallible(decodeADD_hl_r16)
+		t[0x0b|p<<4] = infallible(decodeDEC_r16)
+		t[0xc1|p<<4] = infallible(decodePOP_r16)
+		t[0xc5|p<<4] = infallible(decodePUSH_r16)
+	}
+
+	/* Opcodes parameterised by a register, ALU operation or restart vector in bits 3-5 */
+	for y := 0; y < 8; y++ {
+		t[0x04|y<<3] = infallible(decodeINC_r8)
+		t[0x05|y<<3] = infallible(decodeDEC_r8)
+		t[0x06|y<<3] = decodeLD_r8_n
+		t[0xc6|y<<3] = decodeALU_n
+		t[0xc7|y<<3] = infallible(decodeRST)
+	}
+
+	/* Conditional branches, the condition in bits 3-4 */
+	for cc := 0; cc < 4; cc++ {
+		t[0x20|cc<<3] = decodeJR_cond_E
+		t[0xc0|cc<<3] = decodeRET_cc
+		t[0xc2|cc<<3] = decodeJP_cc_nn
+		t[0xc4|cc<<3] = decodeCALL_cc_nn
+	}
+
+	for op := 0x40; op < 0x80; op++ {
+		t[op] = infallible(decodeLD_r8_r8)
+	}
+	t[0x76] = fixed("halt")
+	for op := 0x80; op < 0xc0; op++ {
+		t[op] = infallible(decodeALU_r8)
+	}
+
+	t[0xe0] = decodeLD_n_A
+	t[0xe8] = decodeADD_SP_n
+	t[0xf0] = decodeLD_A_n
+	t[0xf8] = decodeLD_HL_SP
+	t[0xc9] = fixed("ret")
+	t[0xd9] = fixed("reti")
+	t[0xe9] = infallible(decodeJP_HL)
+	t[0xf9] = infallible(decodeLD_SP_HL)
+	t[0xe2] = infallible(decodeLD_C_A)
+	t[0xea] = decodeLD_nn_A
+	t[0xf2] = infallible(decodeLD_A_C)
+	t[0xfa] = decodeLD_A_nn
+	t[0xc3] = decodeJP_nn
+	t[0xcb] = decodePrefixCB
+	t[0xf3] = fixed("di")
+	t[0xfb] = fixed("ei")
+	t[0xcd] = decodeCALL_nn
+
+	/* Z80 opcodes the SM83 dropped: out, in, ex and the conditional calls on parity and sign */
+	for _, op := range []uint8{0xd3, 0xdb, 0xe3, 0xeb, 0xe4, 0xec, 0xf4, 0xfc} {
+		t[op] = illegal
+	}
+	for _, op := range []uint8{0xdd, 0xed, 0xfd} {
+		t[op] = z80Prefix
+	}
+
+	return checkOpcodeTable("primary", t)
+}
+
+/* Panics if any slot of t was left without a decoder */
+func checkOpcodeTable(name string, t *[256]decodeFunc) *[256]decodeFunc {
+	for op, decode := range t {
+		if decode == nil {
+			panic(fmt.Sprintf("gobjdump: %s opcode 0x%02x has no decoder", name, op))
+		}
+	}
+	return t
+}
+
+/* Returns the primary opcode table for target */
+func opcodeTable(target CPU) *[256]decodeFunc {
+	if target == TargetZ80 {
+		return z80Opcodes
+	}
+	return sm83Opcodes
+}
@@ -0,0 +1,36 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+/* Every primary slot decodes to an instruction or to an explicit illegal marker */
+func TestOpcodeTableHasNoHoles(t *testing.T) {
+	illegal := 0
+	for op := 0; op < 256; op++ {
+		i, _ := DecodeInstruction(bytes.NewReader([]uint8{uint8(op), 0x34, 0x12}), 0x0150)
+		switch {
+		case i.Err == nil && len(i.Mnemonic) > 0:
+		case IsIllegal(i.Err):
+			illegal++
+		default:
+			t.Errorf("0x%02x: neither decoded nor illegal: %q, %v", op, i.Mnemonic, i.Err)
+		}
+	}
+	if illegal != 11 {
+		t.Errorf("%d illegal opcodes, want the 11 the SM83 leaves unused", illegal)
+	}
+}
+
+func TestCheckOpcodeTable(t *testing.T) {
+	for _, table := range []*[256]decodeFunc{sm83Opcodes, cbOpcodes, z80Opcodes} {
+		checkOpcodeTable("test", table)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("checkOpcodeTable did not panic on a hole")
+		}
+	}()
+	checkOpcodeTable("test", new([256]decodeFunc))
+}
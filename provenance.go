@@ -0,0 +1,49 @@
+package gobjdump
+
+import "fmt"
+
+/* Traces a decoded instruction back to the bytes and decoder branch it came from */
+type Provenance struct {
+	FileOffset uint32
+	Bank       uint16
+	Bytes      []uint8
+	DecodePath string
+}
+
+/*
+ * Records where i came from, i having been decoded with ROM bank bank mapped
+ * at 0x4000, as by DisassembleBank; bank 0 stands for bank 1, which the window
+ * shows until a bank is switched in. Instructions below 0x4000 are in bank 0
+ * whatever bank is, and above the window the address is the file offset.
+ */
+func (i *GBInstruction) AttachProvenance(bank uint16) {
+	raw := make([]uint8, len(i.Instruction))
+	copy(raw, i.Instruction)
+	switch {
+	case i.Addr < ROMBankSize || i.Addr >= 2*ROMBankSize:
+		bank = 0
+	case bank == 0:
+		bank = 1
+	}
+	i.Provenance = &Provenance{
+		FileOffset: romOffset(i.Addr, bank),
+		Bank:       bank,
+		Bytes:      raw,
+		DecodePath: DecodePath(i.Instruction),
+	}
+}
+
+/*
+ * Returns the opcode table slots DecodeInstruction dispatches through to
+ * decode instruction, outermost first, e.g. "0xcb/cb:0x7e".
+ */
+func DecodePath(instruction []uint8) string {
+	if len(instruction) == 0 {
+		return ""
+	}
+	path := fmt.Sprintf("0x%02x", instruction[0])
+	if instruction[0] == 0xcb && len(instruction) > 1 {
+		path += fmt.Sprintf("/cb:0x%02x", instruction[1])
+	}
+	return path
+}
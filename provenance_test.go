@@ -0,0 +1,76 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAttachProvenance(t *testing.T) {
+	rom := make([]uint8, 4*ROMBankSize)
+	rom[0x0150] = 0x00
+	rom[1*ROMBankSize+0x10] = 0xcb
+	rom[1*ROMBankSize+0x11] = 0x7e
+	rom[3*ROMBankSize+0x20] = 0xc3
+	tests := []struct {
+		name       string
+		bank       int
+		addr       uint32
+		wantOffset uint32
+		wantBank   uint16
+		wantBytes  []uint8
+		wantPath   string
+	}{
+		{"bank 0", 0, 0x0150, 0x0150, 0, []uint8{0x00}, "0x00"},
+		{"bank 1", 1, 0x4010, 0x4010, 1, []uint8{0xcb, 0x7e}, "0xcb/cb:0x7e"},
+		{"bank 3", 3, 0x4020, 0xc020, 3, []uint8{0xc3, 0x00, 0x00}, "0xc3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, err := DisassembleBank(rom, tt.bank)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := InstructionAt(insns, tt.addr)
+			if i == nil {
+				t.Fatalf("no instruction at 0x%04x", tt.addr)
+			}
+			i.AttachProvenance(uint16(tt.bank))
+			p := i.Provenance
+			if p.FileOffset != tt.wantOffset || p.Bank != tt.wantBank || p.DecodePath != tt.wantPath ||
+				!bytes.Equal(p.Bytes, tt.wantBytes) {
+				t.Errorf("got %+v, want offset 0x%04x bank %d bytes % x path %s",
+					*p, tt.wantOffset, tt.wantBank, tt.wantBytes, tt.wantPath)
+			}
+			if rom[p.FileOffset] != p.Bytes[0] {
+				t.Errorf("rom[0x%04x] = 0x%02x, want the opcode 0x%02x", p.FileOffset, rom[p.FileOffset], p.Bytes[0])
+			}
+		})
+	}
+}
+
+func TestAttachProvenanceCopiesBytes(t *testing.T) {
+	i, _ := DecodeInstruction(bytes.NewReader([]uint8{0x3e, 0x12}), 0x0100)
+	i.AttachProvenance(0)
+	i.Instruction[1] = 0x34
+	if i.Provenance.Bytes[1] != 0x12 {
+		t.Errorf("provenance bytes alias the instruction: % x", i.Provenance.Bytes)
+	}
+}
+
+func TestDecodePath(t *testing.T) {
+	tests := []struct {
+		instruction []uint8
+		want        string
+	}{
+		{nil, ""},
+		{[]uint8{0x00}, "0x00"},
+		{[]uint8{0xc3, 0x50, 0x01}, "0xc3"},
+		{[]uint8{0xcb, 0x37}, "0xcb/cb:0x37"},
+		{[]uint8{0xcb}, "0xcb"},
+	}
+	for _, tt := range tests {
+		if got := DecodePath(tt.instruction); got != tt.want {
+			t.Errorf("DecodePath(% x) = %q, want %q", tt.instruction, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package gobjdump
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+func parseAddr(s string) (uint32, error) {
+	addr, err := strconv.ParseUint(s, 0, 32)
+	return uint32(addr), err
+}
+
+func writeSection(w io.Writer, d *Disassembler, title string, gbInstructions []*GBInstruction) {
+	fmt.Fprintf(w, "---------------- %-40s ----------------\n", title)
+	d.WriteListing(w, gbInstructions)
+}
+
+/*
+ * Runs the gobjdump command line with args (not including the program name),
+ * writing the listing to out and diagnostics to errw. Returns the process
+ * exit code: 0 on success, 1 if disassembly failed and 2 on a usage error.
+ *
+ * With -layout the ROM is listed segment by segment as the layout file says,
+ * see LoadLayout. Otherwise, without -start, -end or -bank the ROM is
+ * analyzed from its entry point. Both are listed by GBROMPreambleTo.
+ */
+func Run(args []string, out io.Writer, errw io.Writer) int {
+	fs := flag.NewFlagSet("gobjdump", flag.ContinueOnError)
+	fs.SetOutput(errw)
+	fs.Usage = func() {
+		fmt.Fprintf(errw, "usage: gobjdump [flags] rom\n")
+		fs.PrintDefaults()
+	}
+	start := fs.String("start", "", "first address to disassemble")
+	end := fs.String("end", "", "address to stop disassembling at")
+	bank := fs.Int("bank", -1, "disassemble a single ROM bank")
+	boot := fs.Bool("boot", false, "treat the input as a DMG boot ROM")
+	syntax := fs.String("syntax", "legacy", "output syntax: legacy or rgbds")
+	warn := fs.Bool("warn", false, "annotate suspicious instructions")
+	mmio := fs.Bool("mmio", false, "annotate writes to well-known I/O registers")
+	labels := fs.Bool("labels", false, "replace branch targets with generated labels")
+	ioregs := fs.Bool("ioregs", false, "name I/O registers accessed through ldh and ld [nn]")
+	ascii := fs.Bool("ascii", false, "show instruction bytes as ASCII")
+	symFile := fs.String("sym", "", "RGBDS or BGB symbol file naming addresses")
+	blocks := fs.Bool("blocks", false, "separate basic blocks with blank lines")
+	layoutFile := fs.String("layout", "", "layout file dividing the ROM into code and data segments")
+	dataRun := fs.Int("data", 0, "show runs of at least this many illegal opcodes as db directives")
+	fill := fs.Int("fill", 0, "collapse runs of at least this many identical fill bytes into one line")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	d := &Disassembler{Warnings: *warn, HaltBug: *warn, DataRun: *dataRun}
+	d.Options.IORegisters = *ioregs
+	d.Options.ASCII = *ascii
+	d.Options.BlockSeparators = *blocks
+	d.Options.CollapseFill = *fill
+	if *mmio {
+		d.Rules = MMIOCommentRules
+	}
+	switch *syntax {
+	case "legacy":
+	case "rgbds":
+		d.Options.RGBDS = true
+	default:
+		fmt.Fprintf(errw, "gobjdump: unknown syntax %q\n", *syntax)
+		return 2
+	}
+
+	rom, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(errw, "gobjdump: %v\n", err)
+		return 1
+	}
+
+	var symbols map[uint32]string
+	if *symFile != "" {
+		f, err := os.Open(*symFile)
+		if err != nil {
+			fmt.Fprintf(errw, "gobjdump: %v\n", err)
+			return 1
+		}
+		symbols, err = LoadSymbols(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(errw, "gobjdump: %v\n", err)
+			return 1
+		}
+	}
+	if *layoutFile != "" || *start == "" && *end == "" && *bank < 0 {
+		opts := PreambleOptions{Disassembler: d, BootROM: *boot, GenerateLabels: *labels, Symbols: symbols}
+		if *layoutFile != "" {
+			f, err := os.Open(*layoutFile)
+			if err != nil {
+				fmt.Fprintf(errw, "gobjdump: %v\n", err)
+				return 1
+			}
+			opts.Layout, err = LoadLayout(f)
+			f.Close()
+			if err != nil {
+				fmt.Fprintf(errw, "gobjdump: %v\n", err)
+				return 1
+			}
+		}
+		if err := GBROMPreambleTo(out, bytes.NewReader(rom), opts); err != nil {
+			fmt.Fprintf(errw, "gobjdump: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	/* With -bank, addresses are those the bank is mapped at rather than file offsets */
+	data, base := rom, uint32(0)
+	if *bank >= 0 {
+		if data, base, err = bankWindow(rom, *bank); err != nil {
+			fmt.Fprintf(errw, "gobjdump: %v\n", err)
+			return 2
+		}
+		d.Options.ShowBank = true
+		d.Options.Bank = uint16(*bank)
+	}
+	from, to := base, base+uint32(len(data))
+	if *start != "" {
+		if from, err = parseAddr(*start); err != nil {
+			fmt.Fprintf(errw, "gobjdump: bad -start: %v\n", err)
+			return 2
+		}
+	}
+	if *end != "" {
+		if to, err = parseAddr(*end); err != nil {
+			fmt.Fprintf(errw, "gobjdump: bad -end: %v\n", err)
+			return 2
+		}
+	}
+	if from < base {
+		fmt.Fprintf(errw, "gobjdump: -start 0x%04x is below bank base 0x%04x\n", from, base)
+		return 2
+	}
+
+	reader := bytes.NewReader(data)
+	reader.Seek(int64(from-base), 0)
+	gbInstructions, err := d.Disassemble(reader, from, to)
+	d.Options.Labels = mergeLabels(gbInstructions, *labels, nil, symbols)
+	d.WriteListing(out, gbInstructions)
+	if err != nil {
+		fmt.Fprintf(errw, "gobjdump: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+package gobjdump
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemp(t *testing.T, name string, data []uint8) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGBROMPreambleTo(t *testing.T) {
+	rom := make([]uint8, 0x0210)
+	copy(rom[0x0100:], []uint8{0x00, 0xc3, 0x50, 0x01})
+	copy(rom[0x0150:], []uint8{0xcd, 0x00, 0x02, 0x18, 0xfb})
+	rom[0x0200] = 0xc9
+	tests := []struct {
+		name string
+		opts PreambleOptions
+		want []string
+	}{
+		{"sections", PreambleOptions{}, []string{
+			"RST and Interrupt table",
+			"Code Entry Point (Trampoline)",
+			"Nintendo Logo",
+			"Code Start",
+			"RST_00:",
+			"0x0101: c35001",
+		}},
+		{"symbols", PreambleOptions{Symbols: map[uint32]string{0x0200: "Helper"}}, []string{
+			"call   Helper",
+			"Helper:",
+		}},
+		{"generated labels", PreambleOptions{GenerateLabels: true}, []string{
+			"call   L_0200",
+		}},
+		{"boot rom", PreambleOptions{BootROM: true}, []string{
+			"Boot ROM",
+		}},
+		{"layout", PreambleOptions{Layout: []Segment{{Start: 0x0150, End: 0x0155, Kind: SegmentCode}}}, []string{
+			"0x0150: cd0002",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := GBROMPreambleTo(&buf, bytes.NewReader(rom), tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestGBROMPreambleToError(t *testing.T) {
+	rom := make([]uint8, 0x0102)
+	var buf bytes.Buffer
+	if err := GBROMPreambleTo(&buf, bytes.NewReader(rom), PreambleOptions{}); err == nil {
+		t.Fatal("want an error for an entry point that runs off the ROM")
+	}
+	if !strings.Contains(buf.String(), "RST and Interrupt table") {
+		t.Errorf("want the sections decoded before the error:\n%s", buf.String())
+	}
+}
+
+/* Run's whole-ROM listing is GBROMPreambleTo's, with the flags passed through */
+func TestRunMatchesGBROMPreambleTo(t *testing.T) {
+	rom := make([]uint8, 0x0210)
+	copy(rom[0x0100:], []uint8{0x00, 0xc3, 0x50, 0x01})
+	copy(rom[0x0150:], []uint8{0xcd, 0x00, 0x02, 0x18, 0xfb})
+	rom[0x0200] = 0xc9
+	romPath := writeTemp(t, "rom.gb", rom)
+	symPath := writeTemp(t, "rom.sym", []uint8("00:0200 Helper\n"))
+
+	var out, errw bytes.Buffer
+	if code := Run([]string{"-labels", "-sym", symPath, romPath}, &out, &errw); code != 0 {
+		t.Fatalf("Run = %d, stderr %q", code, errw.String())
+	}
+	var want bytes.Buffer
+	opts := PreambleOptions{
+		Disassembler:   &Disassembler{},
+		GenerateLabels: true,
+		Symbols:        map[uint32]string{0x0200: "Helper"},
+	}
+	if err := GBROMPreambleTo(&want, bytes.NewReader(rom), opts); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want.String() {
+		t.Errorf("Run output:\n%s\nwant:\n%s", out.String(), want.String())
+	}
+}
+
+func TestRun(t *testing.T) {
+	rom := make([]uint8, 0x8000)
+	copy(rom[0x0150:], []uint8{0x3e, 0x12, 0xcd, 0x00, 0x02, 0x18, 0xf9})
+	copy(rom[0x4000:], []uint8{0x18, 0xfe})
+	romPath := writeTemp(t, "rom.gb", rom)
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  []string
+		wantErr  string
+	}{
+		{"range", []string{"-start", "0x0150", "-end", "0x0155", romPath}, 0,
+			[]string{"0x0150: 3e12         ld     a, 0x12\n0x0152: cd0002       call   0x0200\n"}, ""},
+		{"rgbds", []string{"-syntax", "rgbds", "-start", "0x0150", "-end", "0x0152", romPath}, 0,
+			[]string{"ld     a, $12"}, ""},
+		{"labels", []string{"-labels", "-start", "0x0150", "-end", "0x0157", romPath}, 0,
+			[]string{"L_0150:\n0x0150: 3e12", "jr     L_0150"}, ""},
+		{"bank", []string{"-bank", "1", "-end", "0x4002", romPath}, 0,
+			[]string{"01:4000: 18fe         jr     01:4000"}, ""},
+		{"labels", []string{"-labels", "-start", "0x0150", "-end", "0x0157", romPath}, 0,
+			[]string{"L_0150:\n0x0150: 3e12", "jr     L_0150"}, ""},
+		{"no rom", nil, 2, nil, "usage: gobjdump"},
+		{"unknown flag", []string{"-nope", romPath}, 2, nil, "-nope"},
+		{"unknown syntax", []string{"-syntax", "masm", romPath}, 2, nil, `unknown syntax "masm"`},
+		{"bad start", []string{"-start", "x", romPath}, 2, nil, "bad -start"},
+		{"bad end", []string{"-end", "x", romPath}, 2, nil, "bad -end"},
+		{"start below bank", []string{"-bank", "1", "-start", "0x0150", romPath}, 2, nil, "below bank base"},
+		{"bank out of range", []string{"-bank", "2", romPath}, 2, nil, "bank 2 out of range"},
+		{"missing rom", []string{filepath.Join(t.TempDir(), "missing.gb")}, 1, nil, "missing.gb"},
+		{"missing sym", []string{"-sym", filepath.Join(t.TempDir(), "missing.sym"), romPath}, 1, nil, "missing.sym"},
+		{"missing layout", []string{"-layout", filepath.Join(t.TempDir(), "missing.layout"), romPath}, 1, nil, "missing.layout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errw bytes.Buffer
+			if code := Run(tt.args, &out, &errw); code != tt.wantCode {
+				t.Fatalf("Run = %d, want %d; stderr %q", code, tt.wantCode, errw.String())
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+			if !strings.Contains(errw.String(), tt.wantErr) {
+				t.Errorf("stderr %q, want it to contain %q", errw.String(), tt.wantErr)
+			}
+		})
+	}
+}
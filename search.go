@@ -0,0 +1,57 @@
+package gobjdump
+
+import (
+	"bytes"
+	"sort"
+)
+
+/* Returns the instructions of insns that pred accepts, in order */
+func FindInstructions(insns []*GBInstruction, pred func(*GBInstruction) bool) []*GBInstruction {
+	var found []*GBInstruction
+	for _, i := range insns {
+		if pred(i) {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+/* Matches decoded instructions with mnemonic op, e.g. "call" */
+func ByOpcode(op string) func(*GBInstruction) bool {
+	return func(i *GBInstruction) bool {
+		return op != "" && i.Op() == op
+	}
+}
+
+/* Matches instructions whose bytes start with prefix, e.g. 0xcb, or 0xe0, 0x40 for writes to rLCDC */
+func ByBytePrefix(prefix ...uint8) func(*GBInstruction) bool {
+	return func(i *GBInstruction) bool {
+		return bytes.HasPrefix(i.Instruction, prefix)
+	}
+}
+
+/* Counts the decoded instructions of insns by mnemonic; those that failed to decode are not counted */
+func Histogram(insns []*GBInstruction) map[string]int {
+	counts := make(map[string]int)
+	for _, i := range insns {
+		if op := i.Op(); op != "" {
+			counts[op]++
+		}
+	}
+	return counts
+}
+
+/*
+ * Returns the instruction of insns, in ascending address order, whose bytes
+ * cover addr, or nil if addr falls in a gap or outside insns
+ */
+func InstructionAt(insns []*GBInstruction, addr uint32) *GBInstruction {
+	n := sort.Search(len(insns), func(n int) bool { return insns[n].Addr > addr })
+	if n == 0 {
+		return nil
+	}
+	if i := insns[n-1]; addr < instructionEnd(i) {
+		return i
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package gobjdump
+
+import (
+	"bytes"
+	"maps"
+	"testing"
+)
+
+func TestFindInstructions(t *testing.T) {
+	program := []uint8{
+		0xcd, 0x00, 0x20, // call 0x2000
+		0xcb, 0x37, // swap a
+		0xe0, 0x40, // ldh [0xff40], a
+		0xc4, 0x50, 0x01, // call nz, 0x0150
+		0xe0, 0x41, // ldh [0xff41], a
+		0xc9, // ret
+	}
+	insns, _ := Disassemble(bytes.NewReader(program), 0x0150, 0x0150+uint32(len(program)))
+	tests := []struct {
+		name string
+		pred func(*GBInstruction) bool
+		want []uint32
+	}{
+		{"all calls", ByOpcode("call"), []uint32{0x0150, 0x0157}},
+		{"ret", ByOpcode("ret"), []uint32{0x015c}},
+		{"no match", ByOpcode("halt"), nil},
+		{"empty opcode", ByOpcode(""), nil},
+		{"cb prefix", ByBytePrefix(0xcb), []uint32{0x0153}},
+		{"rLCDC writes", ByBytePrefix(0xe0, 0x40), []uint32{0x0155}},
+		{"every ldh", ByBytePrefix(0xe0), []uint32{0x0155, 0x015a}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := FindInstructions(insns, tt.pred)
+			if len(found) != len(tt.want) {
+				t.Fatalf("got %d instructions, want %d", len(found), len(tt.want))
+			}
+			for n, i := range found {
+				if i.Addr != tt.want[n] {
+					t.Errorf("match %d at 0x%04x, want 0x%04x", n, i.Addr, tt.want[n])
+				}
+			}
+		})
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		want map[string]int
+	}{
+		{"by mnemonic", []uint8{0x00, 0x3e, 0x01, 0x00, 0x78, 0xaf, 0x00}, map[string]int{"nop": 3, "ld": 2, "xor": 1}},
+		{"cb prefixed", []uint8{0xcb, 0x37, 0xcb, 0x7c, 0xcb, 0x30}, map[string]int{"swap": 2, "bit": 1}},
+		{"illegal skipped", []uint8{0xd3, 0x00, 0xdd}, map[string]int{"nop": 1}},
+		{"truncated skipped", []uint8{0x00, 0x3e}, map[string]int{"nop": 1}},
+		{"empty", nil, map[string]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, _ := Disassemble(bytes.NewReader(tt.data), 0x0150, 0x0150+uint32(len(tt.data)))
+			if got := Histogram(insns); !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstructionAt(t *testing.T) {
+	/* ld hl, 0x1234; nop; then a gap; ret at 0x0160 */
+	first, _ := Disassemble(bytes.NewReader([]uint8{0x21, 0x34, 0x12, 0x00}), 0x0150, 0x0154)
+	last, _ := Disassemble(bytes.NewReader([]uint8{0xc9}), 0x0160, 0x0161)
+	insns := append(first, last...)
+	tests := []struct {
+		name string
+		addr uint32
+		want uint32
+		ok   bool
+	}{
+		{"start", 0x0150, 0x0150, true},
+		{"immediate low byte", 0x0151, 0x0150, true},
+		{"immediate high byte", 0x0152, 0x0150, true},
+		{"one byte", 0x0153, 0x0153, true},
+		{"gap", 0x0154, 0, false},
+		{"after the gap", 0x0160, 0x0160, true},
+		{"before", 0x014f, 0, false},
+		{"after", 0x0161, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := InstructionAt(insns, tt.addr)
+			if (i != nil) != tt.ok {
+				t.Fatalf("got %v, want found %v", i, tt.ok)
+			}
+			if tt.ok && i.Addr != tt.want {
+				t.Errorf("got the instruction at 0x%04x, want 0x%04x", i.Addr, tt.want)
+			}
+		})
+	}
+	if i := InstructionAt(nil, 0x0150); i != nil {
+		t.Errorf("empty listing: got %v", i)
+	}
+}
@@ -0,0 +1,38 @@
+package gobjdump
+
+/* A summary of how cleanly a region decoded, see Stats */
+type DisassemblyStats struct {
+	/* Every entry of the listing, whether or not it decoded */
+	Instructions int
+	/* Entries that failed with an illegal opcode */
+	Illegal int
+	/*
+	 * The last byte of the region and how many times it repeats at the end,
+	 * e.g. 0xff and 0x2000 for a bank padded out with 0xff. FillRun is 0 for
+	 * an empty listing.
+	 */
+	FillByte uint8
+	FillRun  int
+}
+
+/* Summarizes insns, a listing in address order such as Disassemble returns */
+func Stats(insns []*GBInstruction) DisassemblyStats {
+	var stats DisassemblyStats
+	stats.Instructions = len(insns)
+	for _, i := range insns {
+		if IsIllegal(i.Err) {
+			stats.Illegal++
+		}
+	}
+	for k := len(insns) - 1; k >= 0; k-- {
+		b := insns[k].Instruction
+		for n := len(b) - 1; n >= 0; n-- {
+			if stats.FillRun > 0 && b[n] != stats.FillByte {
+				return stats
+			}
+			stats.FillByte = b[n]
+			stats.FillRun++
+		}
+	}
+	return stats
+}
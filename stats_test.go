@@ -0,0 +1,36 @@
+package gobjdump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	pad := func(code []uint8, fill uint8, n int) []uint8 {
+		return append(code, bytes.Repeat([]uint8{fill}, n)...)
+	}
+	tests := []struct {
+		name string
+		data []uint8
+		want DisassemblyStats
+	}{
+		{"0xff padding", pad([]uint8{0x3e, 0x12, 0xc9}, 0xff, 0x20),
+			DisassemblyStats{Instructions: 0x22, FillByte: 0xff, FillRun: 0x20}},
+		{"0x00 padding", pad([]uint8{0x3e, 0x12, 0xc9}, 0x00, 0x10),
+			DisassemblyStats{Instructions: 0x12, FillByte: 0x00, FillRun: 0x10}},
+		/* The fill counts bytes, so it runs into the operand of the ld before it */
+		{"fill in an operand", pad([]uint8{0x00, 0x3e, 0xff}, 0xff, 3),
+			DisassemblyStats{Instructions: 5, FillByte: 0xff, FillRun: 4}},
+		{"illegal opcodes", []uint8{0xd3, 0x00, 0xdd, 0xc9},
+			DisassemblyStats{Instructions: 4, Illegal: 2, FillByte: 0xc9, FillRun: 1}},
+		{"empty", nil, DisassemblyStats{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, _ := Disassemble(bytes.NewReader(tt.data), 0x0150, 0x0150+uint32(len(tt.data)))
+			if got := Stats(insns); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
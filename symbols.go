@@ -0,0 +1,78 @@
+package gobjdump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Returns the Labels key naming addr in ROM bank bank. Addresses in the
+ * switchable window of bank 2 and up carry the bank above the low 16 bits, so
+ * that the same address in two banks can have two names; everything else,
+ * bank 1 included since it is what the window shows unbanked, is keyed by
+ * the address alone.
+ */
+func SymbolKey(bank uint16, addr uint32) uint32 {
+	if addr >= ROMBankSize && addr < 2*ROMBankSize && bank > 1 {
+		return uint32(bank)<<16 | addr
+	}
+	return addr
+}
+
+/*
+ * Returns the label of addr as seen from ROM bank bank: for an addr in the
+ * switchable window, the name keyed by bank and addr, see SymbolKey; failing
+ * that, the name keyed by addr alone, such as a generated label
+ */
+func labelAt(labels map[uint32]string, addr uint32, bank uint16) (string, bool) {
+	if b, ok := targetBank(addr, bank); ok {
+		if label, ok := labels[SymbolKey(b, addr)]; ok {
+			return label, true
+		}
+	}
+	label, ok := labels[addr]
+	return label, ok
+}
+
+/*
+ * Parses an RGBDS or BGB .sym file, lines like "01:4150 Main". Comments start
+ * with ';'. Keys are bank and address, see SymbolKey. When a file names an
+ * address more than once the last name wins.
+ */
+func LoadSymbols(r io.Reader) (map[uint32]string, error) {
+	symbols := make(map[uint32]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if n := strings.IndexByte(text, ';'); n >= 0 {
+			text = text[:n]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("sym line %d: want \"bank:address name\", got %q", line, scanner.Text())
+		}
+		bankText, addrText, ok := strings.Cut(fields[0], ":")
+		if !ok {
+			return nil, fmt.Errorf("sym line %d: missing ':' in %q", line, fields[0])
+		}
+		bank, err := strconv.ParseUint(bankText, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("sym line %d: bad bank %q", line, bankText)
+		}
+		addr, err := strconv.ParseUint(addrText, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("sym line %d: bad address %q", line, addrText)
+		}
+		symbols[SymbolKey(uint16(bank), uint32(addr))] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return symbols, nil
+}
@@ -0,0 +1,101 @@
+package gobjdump
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testSymFile = `; RGBDS symbols
+00:0150 Main
+00:0028 _WaitVBlank
+01:4000 BankOne
+02:4000 BankTwo
+00:c000 wBuffer
+`
+
+func TestLoadSymbols(t *testing.T) {
+	symbols, err := LoadSymbols(strings.NewReader(testSymFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint32]string{
+		0x0150:  "Main",
+		0x0028:  "_WaitVBlank",
+		0x4000:  "BankOne",
+		0x24000: "BankTwo",
+		0xc000:  "wBuffer",
+	}
+	if len(symbols) != len(want) {
+		t.Errorf("got %d symbols, want %d: %v", len(symbols), len(want), symbols)
+	}
+	for key, name := range want {
+		if symbols[key] != name {
+			t.Errorf("symbols[0x%x] = %q, want %q", key, symbols[key], name)
+		}
+	}
+}
+
+func TestLoadSymbolsErrors(t *testing.T) {
+	for _, text := range []string{
+		"0150 Main\n",
+		"00:0150\n",
+		"zz:0150 Main\n",
+		"00:10150 Main\n",
+	} {
+		if _, err := LoadSymbols(strings.NewReader(text)); err == nil {
+			t.Errorf("LoadSymbols(%q) succeeded", text)
+		}
+	}
+}
+
+func TestSymbolsNameBranchTargets(t *testing.T) {
+	symbols, err := LoadSymbols(strings.NewReader(testSymFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []uint8
+		bank uint16
+		want string
+	}{
+		{"bank 0 call", []uint8{0xcd, 0x50, 0x01}, 0, "call   Main"},
+		{"unbanked window", []uint8{0xc3, 0x00, 0x40}, 0, "jp     BankOne"},
+		{"bank 1", []uint8{0xc3, 0x00, 0x40}, 1, "jp     BankOne"},
+		{"bank 2", []uint8{0xc3, 0x00, 0x40}, 2, "jp     BankTwo"},
+		{"bank 3 falls back to the bare address", []uint8{0xc3, 0x00, 0x40}, 3, "jp     BankOne"},
+		{"unnamed", []uint8{0xcd, 0x00, 0x20}, 0, "call   0x2000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x4100)
+			got := i.ToStrWithOptions(FormatOptions{Labels: symbols, Bank: tt.bank})
+			if !strings.HasSuffix(got, tt.want) {
+				t.Errorf("got %q, want suffix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSymbolsLabelBankedListing(t *testing.T) {
+	symbols, err := LoadSymbols(strings.NewReader(testSymFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	insns, err := Disassemble(bytes.NewReader([]uint8{0x00, 0x18, 0xfd}), 0x4000, 0x4003)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Disassembler{Options: FormatOptions{Labels: symbols, ShowBank: true, Bank: 2}}
+	var out bytes.Buffer
+	if err := d.WriteListing(&out, insns); err != nil {
+		t.Fatal(err)
+	}
+	want := "BankTwo:\n" +
+		"02:4000: 00           nop\n" +
+		"02:4001: 18fd         jr     BankTwo\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,37 @@
+package gobjdump
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+/* Separates operands within the operands column, which may not contain commas */
+const tsvOperandSeparator = ";"
+
+/*
+ * Returns i as tab-separated columns: address, bytes, mnemonic, operands
+ * joined by ";" and error. The mnemonic and operands are empty for an
+ * instruction that failed to decode, the error for one that did not.
+ */
+func (i *GBInstruction) ToTSV() string {
+	errText := ""
+	if i.Err != nil {
+		errText = i.Err.Error()
+	}
+	return fmt.Sprintf("0x%04x\t%s\t%s\t%s\t%s", i.Addr, appendHex(nil, i.Instruction),
+		i.Op(), strings.Join(i.Operands(), tsvOperandSeparator), errText)
+}
+
+/* Writes insns one per line with ToTSV, after a header row naming the columns */
+func WriteTSV(w io.Writer, insns []*GBInstruction) error {
+	if _, err := io.WriteString(w, "addr\tbytes\tmnemonic\toperands\terror\n"); err != nil {
+		return err
+	}
+	for _, i := range insns {
+		if _, err := fmt.Fprintf(w, "%s\n", i.ToTSV()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
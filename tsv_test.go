@@ -0,0 +1,53 @@
+package gobjdump
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestToTSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		want string
+	}{
+		{"two operands", []uint8{0x3e, 0x12}, "0x0150\t3e12\tld\ta;0x12\t"},
+		{"no operands", []uint8{0x00}, "0x0150\t00\tnop\t\t"},
+		{"spaces in an operand", []uint8{0xe2}, "0x0150\te2\tld\t[0xff00 + C];a\t"},
+		{"illegal", []uint8{0xd3}, "0x0150\td3\t\t\tIllegal Instruction"},
+		{"truncated", []uint8{0xc3, 0x50}, "0x0150\tc350\t\t\tMalformed Instruction"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			got := i.ToTSV()
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if columns := strings.Split(got, "\t"); len(columns) != 5 || strings.Contains(columns[3], ",") {
+				t.Errorf("columns %q, want five with no comma among the operands", columns)
+			}
+		})
+	}
+}
+
+func TestWriteTSV(t *testing.T) {
+	insns, _ := Disassemble(bytes.NewReader([]uint8{0x3e, 0x12, 0xc9}), 0x0150, 0x0153)
+	var buf bytes.Buffer
+	if err := WriteTSV(&buf, insns); err != nil {
+		t.Fatal(err)
+	}
+	want := "addr\tbytes\tmnemonic\toperands\terror\n" +
+		"0x0150\t3e12\tld\ta;0x12\t\n" +
+		"0x0152\tc9\tret\t\t\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	for n := 0; n < 3; n++ {
+		if err := WriteTSV(&failingWriter{n: n}, insns); !errors.Is(err, errFlaky) {
+			t.Errorf("failing after %d writes: err = %v, want %v", n, err, errFlaky)
+		}
+	}
+}
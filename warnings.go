@@ -0,0 +1,96 @@
+package gobjdump
+
+import (
+	"fmt"
+	"slices"
+)
+
+/*
+ * Returns advisory warnings for legal instructions that usually mean a linear
+ * sweep has run into data:
+ *   - a jr, jp or call to 0x0000
+ *   - a jr, jp or call into 0xfe00-0xff7f (OAM, unusable, I/O) or to 0xffff
+ *   - ld r, r with the same source and destination, except the ld b, b
+ *     breakpoint used by emulators
+ *   - rst 0x38, which is what 0xff fill bytes decode as
+ */
+func SuspiciousWarnings(i *GBInstruction) []string {
+	if i.Err != nil || len(i.Instruction) == 0 {
+		return nil
+	}
+	var warnings []string
+	op := i.Instruction[0]
+	if target, ok := branchTarget(i); ok && op&0xc7 != 0xc7 {
+		switch {
+		case target == 0x0000:
+			warnings = append(warnings, "warning: control transfer to 0x0000")
+		case target >= 0xfe00 && target < 0xff80, target == 0xffff:
+			warnings = append(warnings, fmt.Sprintf("warning: control transfer into I/O space at 0x%04x", target))
+		}
+	}
+	if op&0xc0 == 0x40 && op != 0x76 && op != 0x40 && (op>>3)&0x07 == op&0x07 {
+		warnings = append(warnings, "warning: ld to itself")
+	}
+	if op == 0xff {
+		warnings = append(warnings, "warning: rst 0x38 is usually 0xff fill")
+	}
+	return warnings
+}
+
+/*
+ * Warns about a halt followed, through Next, by anything but a nop: with
+ * interrupts disabled and one pending, the halt bug makes the CPU read the
+ * byte after the halt twice. Assemblers pad halts with a nop for this.
+ */
+func HaltBugWarning(i *GBInstruction) (string, bool) {
+	if i.Err != nil || len(i.Instruction) == 0 || i.Instruction[0] != 0x76 || i.Next == nil {
+		return "", false
+	}
+	if next := i.Next; next.Err == nil && len(next.Instruction) > 0 && next.Instruction[0] == 0x00 {
+		return "", false
+	}
+	return "warning: halt bug runs the next byte twice if interrupts are disabled", true
+}
+
+/* Attached by Disassemble to the instructions UnloadedHLAccesses returns */
+const UnloadedHLWarning = "warning: [hl] accessed with hl not loaded in this block"
+
+/*
+ * Returns the instructions of insns, in address order, that access memory
+ * through [hl] without hl having been written since the start of insns, the
+ * last branch target in insns, the last address named in labels as seen from
+ * ROM bank bank, or the last unconditional jp, jr or ret. Any instruction with
+ * h, l or hl as its destination, such as pop hl, and ex de, hl count as
+ * writes, as do calls and rsts since the callee may leave hl set. jp hl jumps
+ * through hl rather than accessing memory and is never returned.
+ */
+func UnloadedHLAccesses(insns []*GBInstruction, labels map[uint32]string, bank uint16) []*GBInstruction {
+	targets := make(map[uint32]bool)
+	for _, i := range insns {
+		if target, ok := branchTarget(i); ok {
+			targets[target] = true
+		}
+	}
+	var found []*GBInstruction
+	loaded := false
+	for _, i := range insns {
+		if _, ok := labelAt(labels, i.Addr, bank); ok || targets[i.Addr] {
+			loaded = false
+		}
+		operands := i.Operands()
+		if !loaded && i.Flow != FlowIndirectJump && slices.Contains(operands, "[hl]") {
+			found = append(found, i)
+		}
+		switch {
+		case !i.Flow.FallsThrough():
+			/* Whatever follows is only reached from elsewhere */
+			loaded = false
+		case len(operands) == 0:
+		case i.Flow == FlowCall,
+			operands[0] == "h", operands[0] == "l", operands[0] == "hl",
+			i.Op() == "ex" && operands[len(operands)-1] == "hl":
+			loaded = true
+		}
+	}
+	return found
+}
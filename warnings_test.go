@@ -0,0 +1,164 @@
+package gobjdump
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestSuspiciousWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		data []uint8
+		want []string
+	}{
+		{"jp 0x0000", []uint8{0xc3, 0x00, 0x00}, []string{"warning: control transfer to 0x0000"}},
+		{"call 0xffff", []uint8{0xcd, 0xff, 0xff}, []string{"warning: control transfer into I/O space at 0xffff"}},
+		{"jp into OAM", []uint8{0xc3, 0x00, 0xfe}, []string{"warning: control transfer into I/O space at 0xfe00"}},
+		{"call into HRAM", []uint8{0xcd, 0x80, 0xff}, nil},
+		{"ld c, c", []uint8{0x49}, []string{"warning: ld to itself"}},
+		{"ld b, b breakpoint", []uint8{0x40}, nil},
+		{"rst 0x38", []uint8{0xff}, []string{"warning: rst 0x38 is usually 0xff fill"}},
+		{"rst 0x00", []uint8{0xc7}, nil},
+		{"ld a, b", []uint8{0x78}, nil},
+		{"illegal", []uint8{0xd3}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, _ := DecodeInstruction(bytes.NewReader(tt.data), 0x0150)
+			if got := SuspiciousWarnings(i); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnloadedHLAccesses(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []uint8
+		labels map[uint32]string
+		want   []uint32
+	}{
+		/* ld a, [hl] */
+		{"first access", []uint8{0x7e}, nil, []uint32{0x0150}},
+		/* ld hl, 0xc000; ld a, [hl]; ld [hl], 0x00 */
+		{"after ld hl, nn", []uint8{0x21, 0x00, 0xc0, 0x7e, 0x36, 0x00}, nil, nil},
+		/* pop hl; inc [hl] */
+		{"after pop hl", []uint8{0xe1, 0x34}, nil, nil},
+		/* ld h, a; ld l, b; ldi a, [hl] */
+		{"after ld h and ld l", []uint8{0x67, 0x68, 0x2a}, nil, nil},
+		/* call 0x2000; ld a, [hl] */
+		{"after a call", []uint8{0xcd, 0x00, 0x20, 0x7e}, nil, nil},
+		/* ld hl, 0xc000; nop; ld a, [hl]; jr -3 */
+		{"at a branch target", []uint8{0x21, 0x00, 0xc0, 0x00, 0x7e, 0x18, 0xfd}, nil, []uint32{0x0154}},
+		/* ld hl, 0xc000; ld a, [hl] */
+		{"at a label", []uint8{0x21, 0x00, 0xc0, 0x7e}, map[uint32]string{0x0153: "Next"}, []uint32{0x0153}},
+		/* ld hl, 0xc000; ret; ld a, [hl] */
+		{"after ret", []uint8{0x21, 0x00, 0xc0, 0xc9, 0x7e}, nil, []uint32{0x0154}},
+		/* ld hl, 0xc000; jr -5; ld a, [hl] */
+		{"after jr", []uint8{0x21, 0x00, 0xc0, 0x18, 0xfb, 0x7e}, nil, []uint32{0x0155}},
+		/* ld hl, 0xc000; ret nz; ld a, [hl] */
+		{"after ret nz", []uint8{0x21, 0x00, 0xc0, 0xc0, 0x7e}, nil, nil},
+		/* jp hl */
+		{"jp hl", []uint8{0xe9}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, err := Disassemble(bytes.NewReader(tt.data), 0x0150, 0x0150+uint32(len(tt.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint32
+			for _, i := range UnloadedHLAccesses(insns, tt.labels, 0) {
+				got = append(got, i.Addr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %#x, want %#x", got, tt.want)
+			}
+		})
+	}
+}
+
+/* A label keyed by bank only starts a block when listing that bank */
+func TestUnloadedHLAccessesBankedLabel(t *testing.T) {
+	/* ld hl, 0xc000; ld a, [hl] */
+	insns, err := Disassemble(bytes.NewReader([]uint8{0x21, 0x00, 0xc0, 0x7e}), 0x4000, 0x4004)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := map[uint32]string{SymbolKey(2, 0x4003): "BankTwoNext"}
+	for _, tt := range []struct {
+		bank uint16
+		want int
+	}{{2, 1}, {3, 0}} {
+		if got := UnloadedHLAccesses(insns, labels, tt.bank); len(got) != tt.want {
+			t.Errorf("bank %d: %d accesses, want %d", tt.bank, len(got), tt.want)
+		}
+	}
+}
+
+func TestDisassemblerWarnings(t *testing.T) {
+	/* ld a, [hl]; jp 0x0000 */
+	data := []uint8{0x7e, 0xc3, 0x00, 0x00}
+	for _, warn := range []bool{false, true} {
+		d := &Disassembler{Warnings: warn}
+		insns, err := d.Disassemble(bytes.NewReader(data), 0x0150, 0x0154)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want [][]string
+		if warn {
+			want = [][]string{{UnloadedHLWarning}, {"warning: control transfer to 0x0000"}}
+		} else {
+			want = [][]string{nil, nil}
+		}
+		for n, i := range insns {
+			if !slices.Equal(i.Comments, want[n]) {
+				t.Errorf("Warnings %v: comments of %s = %q, want %q", warn, i, i.Comments, want[n])
+			}
+		}
+	}
+}
+
+func TestHaltBugWarning(t *testing.T) {
+	const warning = "warning: halt bug runs the next byte twice if interrupts are disabled"
+	tests := []struct {
+		name string
+		data []uint8
+		want bool
+	}{
+		{"halt then ld a, b", []uint8{0x76, 0x78}, true},
+		{"halt then nop", []uint8{0x76, 0x00}, false},
+		{"halt then illegal", []uint8{0x76, 0xd3}, true},
+		{"halt last", []uint8{0x76}, false},
+		{"not halt", []uint8{0x00, 0x78}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insns, _ := Disassemble(bytes.NewReader(tt.data), 0x0150, 0x0150+uint32(len(tt.data)))
+			got, ok := HaltBugWarning(insns[0])
+			if ok != tt.want || ok && got != warning {
+				t.Errorf("got %q, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+/* Disassembler.HaltBug attaches the warning to the halt only */
+func TestDisassemblerHaltBug(t *testing.T) {
+	data := []uint8{0x76, 0x78, 0x76, 0x00}
+	for _, haltBug := range []bool{false, true} {
+		d := &Disassembler{HaltBug: haltBug}
+		insns, _ := d.Disassemble(bytes.NewReader(data), 0x0150, 0x0154)
+		want := [][]string{nil, nil, nil, nil}
+		if haltBug {
+			want[0] = []string{"warning: halt bug runs the next byte twice if interrupts are disabled"}
+		}
+		for n, i := range insns {
+			if !slices.Equal(i.Comments, want[n]) {
+				t.Errorf("HaltBug %v: comments of %s = %q, want %q", haltBug, i, i.Comments, want[n])
+			}
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package gobjdump
+
+import "fmt"
+
+/*
+ * Decoders for the Z80 instructions the SM83 dropped, reached only when
+ * decoding for TargetZ80. The Z80 table starts from the SM83 one and
+ * overrides the opcodes the two cores encode differently.
+ */
+
+var interruptModes = []string{
+	"0",
+	"0/1",
+	"1",
+	"2",
+	"0",
+	"0/1",
+	"1",
+	"2",
+}
+
+/* All eight Z80 conditions, adding parity (PO, PE) and sign (P, M) to those of the SM83 */
+var z80Conditions = []string{
+	"NZ",
+	"Z",
+	"NC",
+	"C",
+	"PO",
+	"PE",
+	"P",
+	"M",
+}
+
+var blockInstructions = [4][4]string{
+	[4]string{"ldi", "cpi", "ini", "outi"},
+	[4]string{"ldd", "cpd", "ind", "outd"},
+	[4]string{"ldir", "cpir", "inir", "otir"},
+	[4]string{"lddr", "cpdr", "indr", "otdr"},
+}
+
+func decodeDJNZ(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	*mnemonic = append(*mnemonic, "djnz")
+	/* Read operand (next byte) */
+	operand, err := imm8_s(r, instruction)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, operand)
+	return nil
+}
+
+func decodeEX_AF_AF(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	*mnemonic = append(*mnemonic, "ex")
+	*mnemonic = append(*mnemonic, "af")
+	*mnemonic = append(*mnemonic, "af'")
+}
+
+func decodeZ80RET_cc(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	cc := ((*instruction)[0] & 0x38) >> 3
+	*mnemonic = append(*mnemonic, "ret")
+	*mnemonic = append(*mnemonic, z80Conditions[cc])
+}
+
+func decodeZ80JP_cc_nn(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	cc := ((*instruction)[0] & 0x38) >> 3
+	*mnemonic = append(*mnemonic, "jp")
+	*mnemonic = append(*mnemonic, z80Conditions[cc])
+	operand, err := imm16(r, instruction)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, operand)
+	return nil
+}
+
+func decodeZ80CALL_cc_nn(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	cc := ((*instruction)[0] & 0x38) >> 3
+	*mnemonic = append(*mnemonic, "call")
+	*mnemonic = append(*mnemonic, z80Conditions[cc])
+	operand, err := imm16(r, instruction)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, operand)
+	return nil
+}
+
+func decodeLD_nn_HL(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	*mnemonic = append(*mnemonic, "ld")
+	operand, err := imm16_addr(r, instruction)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, operand)
+	*mnemonic = append(*mnemonic, "hl")
+	return nil
+}
+
+func decodeLD_HL_nn(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	*mnemonic = append(*mnemonic, "ld")
+	*mnemonic = append(*mnemonic, "hl")
+	operand, err := imm16_addr(r, instruction)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, operand)
+	return nil
+}
+
+func decodeOUT_n_A(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	*mnemonic = append(*mnemonic, "out")
+	operand, err := imm8(r, instruction)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, fmt.Sprintf("[%s]", operand))
+	*mnemonic = append(*mnemonic, "a")
+	return nil
+}
+
+func decodeIN_a_n(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	*mnemonic = append(*mnemonic, "in")
+	*mnemonic = append(*mnemonic, "a")
+	operand, err := imm8(r, instruction)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, fmt.Sprintf("[%s]", operand))
+	return nil
+}
+
+func decodeEX_SP_HL(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	*mnemonic = append(*mnemonic, "ex")
+	*mnemonic = append(*mnemonic, "[sp]")
+	*mnemonic = append(*mnemonic, "hl")
+}
+
+func decodeEX_DE_HL(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	*mnemonic = append(*mnemonic, "ex")
+	*mnemonic = append(*mnemonic, "de")
+	*mnemonic = append(*mnemonic, "hl")
+}
+
+func decodeIN_C(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	*mnemonic = append(*mnemonic, "in")
+	*mnemonic = append(*mnemonic, "[c]")
+}
+
+func decodeIN_r8_C(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	reg_index := ((*instruction)[1] & 0x38) >> 3
+	*mnemonic = append(*mnemonic, "in")
+	*mnemonic = append(*mnemonic, r8[reg_index])
+	*mnemonic = append(*mnemonic, "[c]")
+}
+
+func decodeOUT_C(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	*mnemonic = append(*mnemonic, "out")
+	*mnemonic = append(*mnemonic, "[c]")
+	*mnemonic = append(*mnemonic, "0")
+}
+
+func decodeOUT_r8_C(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	reg_index := ((*instruction)[1] & 0x38) >> 3
+	*mnemonic = append(*mnemonic, "out")
+	*mnemonic = append(*mnemonic, "[c]")
+	*mnemonic = append(*mnemonic, r8[reg_index])
+}
+
+func decodeSBC_HL_r16(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	reg_index := ((*instruction)[1] & 0x30) >> 4
+	*mnemonic = append(*mnemonic, "sbc")
+	*mnemonic = append(*mnemonic, "hl")
+	*mnemonic = append(*mnemonic, r16_sp[reg_index])
+}
+
+func decodeADC_HL_r16(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	reg_index := ((*instruction)[1] & 0x30) >> 4
+	*mnemonic = append(*mnemonic, "adc")
+	*mnemonic = append(*mnemonic, "hl")
+	*mnemonic = append(*mnemonic, r16_sp[reg_index])
+}
+
+func decodeLD_nn_r16(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	reg_index := ((*instruction)[1] & 0x30) >> 4
+	*mnemonic = append(*mnemonic, "ld")
+	operand, err := imm16_addr(r, instruction)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, operand)
+	*mnemonic = append(*mnemonic, r16_sp[reg_index])
+	return nil
+}
+
+func decodeLD_r16_nn_addr(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	reg_index := ((*instruction)[1] & 0x30) >> 4
+	*mnemonic = append(*mnemonic, "ld")
+	operand, err := imm16_addr(r, instruction)
+	if err != nil {
+		return err
+	}
+	*mnemonic = append(*mnemonic, r16_sp[reg_index])
+	*mnemonic = append(*mnemonic, operand)
+	return nil
+}
+
+func decodeIM_im(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	im := ((*instruction)[1] & 0x38) >> 3
+	*mnemonic = append(*mnemonic, "im")
+	*mnemonic = append(*mnemonic, interruptModes[im])
+}
+
+func decodeLD_dst_src(dst string, src string, r Reader, instruction *[]uint8, mnemonic *[]string) {
+	*mnemonic = append(*mnemonic, "ld")
+	*mnemonic = append(*mnemonic, dst)
+	*mnemonic = append(*mnemonic, src)
+}
+
+func decodeBLI(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	a := (((*instruction)[1] & 0x38) >> 3) - 4
+	b := (*instruction)[1] & 0x07
+	*mnemonic = append(*mnemonic, blockInstructions[a][b])
+}
+
+/* Decodes an 0xed-prefixed opcode */
+func decodePrefixED(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	op, err := readImm(r, instruction, 1)
+	if err != nil {
+		return err
+	}
+	return edOpcodes[op[0]](r, instruction, mnemonic)
+}
+
+func unimplemented(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	return &Z80AsmError{errorType: Z80AsmErrorUnimplementedInstruction}
+}
+
+var z80Opcodes = func() *[256]decodeFunc {
+	t := new([256]decodeFunc)
+	*t = *sm83Opcodes
+	t[0x08] = infallible(decodeEX_AF_AF)
+	t[0x10] = decodeDJNZ
+	/* The SM83 reused these for ldi and ldd */
+	t[0x22] = decodeLD_nn_HL
+	t[0x2a] = decodeLD_HL_nn
+	t[0x32] = decodeLD_nn_A
+	t[0x3a] = decodeLD_A_nn
+	/* The SM83 reused the parity and sign conditions for its high-RAM and stack loads */
+	for cc := 4; cc < 8; cc++ {
+		t[0xc0|cc<<3] = infallible(decodeZ80RET_cc)
+		t[0xc2|cc<<3] = decodeZ80JP_cc_nn
+		t[0xc4|cc<<3] = decodeZ80CALL_cc_nn
+	}
+	t[0xd3] = decodeOUT_n_A
+	t[0xdb] = decodeIN_a_n
+	t[0xd9] = fixed("exx")
+	t[0xe3] = infallible(decodeEX_SP_HL)
+	t[0xeb] = infallible(decodeEX_DE_HL)
+	t[0xcb] = decodeZ80PrefixCB
+	t[0xed] = decodePrefixED
+	/* The ix and iy prefixes */
+	t[0xdd] = unimplemented
+	t[0xfd] = unimplemented
+	return checkOpcodeTable("z80", t)
+}()
+
+/* The 0xcb-prefixed opcodes, those of the SM83 but for the undocumented sll in place of swap */
+var z80CBOpcodes = func() *[256]decodeFunc {
+	t := new([256]decodeFunc)
+	*t = *cbOpcodes
+	for op := 0x30; op < 0x38; op++ {
+		t[op] = infallible(decodeSLL_r8)
+	}
+	return checkOpcodeTable("z80 0xcb", t)
+}()
+
+func decodeZ80PrefixCB(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+	op, err := readImm(r, instruction, 1)
+	if err != nil {
+		return err
+	}
+	return z80CBOpcodes[op[0]](r, instruction, mnemonic)
+}
+
+/* Shifts left, setting bit 0 */
+func decodeSLL_r8(r Reader, instruction *[]uint8, mnemonic *[]string) {
+	*mnemonic = append(*mnemonic, "sll")
+	*mnemonic = append(*mnemonic, r8[(*instruction)[1]&0x07])
+}
+
+/* The 0xed-prefixed opcodes, indexed by the byte after the prefix; the unassigned ones act as two-byte nops on a Z80 and are rejected */
+var edOpcodes = func() *[256]decodeFunc {
+	t := new([256]decodeFunc)
+	for op := range t {
+		t[op] = illegal
+	}
+	for y := 0; y < 8; y++ {
+		t[0x40|y<<3] = infallible(decodeIN_r8_C)
+		t[0x41|y<<3] = infallible(decodeOUT_r8_C)
+		t[0x44|y<<3] = fixed("neg")
+		t[0x45|y<<3] = fixed("retn")
+		t[0x46|y<<3] = infallible(decodeIM_im)
+	}
+	t[0x70] = infallible(decodeIN_C)
+	t[0x71] = infallible(decodeOUT_C)
+	t[0x4d] = fixed("reti")
+	for p := 0; p < 4; p++ {
+		t[0x42|p<<4] = infallible(decodeSBC_HL_r16)
+		t[0x4a|p<<4] = infallible(decodeADC_HL_r16)
+		t[0x43|p<<4] = decodeLD_nn_r16
+		t[0x4b|p<<4] = decodeLD_r16_nn_addr
+	}
+	for n, regs := range [][2]string{{"i", "a"}, {"r", "a"}, {"a", "i"}, {"a", "r"}} {
+		dst, src := regs[0], regs[1]
+		t[0x47|n<<3] = func(r Reader, instruction *[]uint8, mnemonic *[]string) error {
+			decodeLD_dst_src(dst, src, r, instruction, mnemonic)
+			return nil
+		}
+	}
+	t[0x67] = fixed("rrd")
+	t[0x6f] = fixed("rld")
+	for y := 4; y < 8; y++ {
+		for z := 0; z < 4; z++ {
+			t[0x80|y<<3|z] = infallible(decodeBLI)
+		}
+	}
+	return t
+}()
@@ -0,0 +1,152 @@
+package gobjdump
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func decodeText(t *testing.T, data []uint8, target CPU) (*GBInstruction, string) {
+	t.Helper()
+	i, _ := DecodeInstructionFor(bytes.NewReader(data), 0x0150, target)
+	if i == nil {
+		t.Fatalf("% x: no instruction", data)
+	}
+	return i, strings.TrimSpace(i.Op() + " " + strings.Join(i.Operands(), ", "))
+}
+
+func TestDecode0x10ByTarget(t *testing.T) {
+	tests := []struct {
+		target   CPU
+		data     []uint8
+		want     string
+		wantFlow FlowType
+	}{
+		{TargetSM83, []uint8{0x10, 0x00}, "stop", FlowHalt},
+		{TargetZ80, []uint8{0x10, 0xfe}, "djnz -2", FlowBranch},
+		{TargetZ80, []uint8{0x10, 0x05}, "djnz 5", FlowBranch},
+	}
+	for _, tt := range tests {
+		i, got := decodeText(t, tt.data, tt.target)
+		if got != tt.want || i.Flow != tt.wantFlow {
+			t.Errorf("target %d, % x: %q %v, want %q %v", tt.target, tt.data, got, i.Flow, tt.want, tt.wantFlow)
+		}
+	}
+	i, _ := decodeText(t, []uint8{0x10, 0xfe}, TargetZ80)
+	if i.ResolvedTarget == nil || *i.ResolvedTarget != 0x0150 {
+		t.Errorf("djnz -2 at 0x0150 resolves to %v, want 0x0150", i.ResolvedTarget)
+	}
+}
+
+func TestDecodeZ80ED(t *testing.T) {
+	tests := []struct {
+		data []uint8
+		want string
+	}{
+		{[]uint8{0xed, 0x40}, "in b, [c]"},
+		{[]uint8{0xed, 0x78}, "in a, [c]"},
+		{[]uint8{0xed, 0x70}, "in [c]"},
+		{[]uint8{0xed, 0x41}, "out [c], b"},
+		{[]uint8{0xed, 0x42}, "sbc hl, bc"},
+		{[]uint8{0xed, 0x72}, "sbc hl, sp"},
+		{[]uint8{0xed, 0x4a}, "adc hl, bc"},
+		{[]uint8{0xed, 0xb0}, "ldir"},
+		{[]uint8{0xed, 0xb8}, "lddr"},
+		{[]uint8{0xed, 0xb1}, "cpir"},
+		{[]uint8{0xed, 0x43, 0x34, 0x12}, "ld [0x1234], bc"},
+		{[]uint8{0xed, 0x45}, "retn"},
+	}
+	for _, tt := range tests {
+		i, got := decodeText(t, tt.data, TargetZ80)
+		if i.Err != nil || got != tt.want {
+			t.Errorf("% x: %q %v, want %q", tt.data, got, i.Err, tt.want)
+		}
+		if i.Len() != len(tt.data) {
+			t.Errorf("% x: consumed %d bytes", tt.data, i.Len())
+		}
+	}
+
+	/* On the SM83 the prefix stays illegal and its operand bytes are not consumed */
+	i, _ := decodeText(t, []uint8{0xed, 0xb0}, TargetSM83)
+	if !IsZ80Prefix(i.Err) || i.Len() != 1 {
+		t.Errorf("SM83 ed b0: %v over %d bytes, want a one-byte Z80 prefix error", i.Err, i.Len())
+	}
+	i, _ = decodeText(t, []uint8{0xed, 0x00}, TargetZ80)
+	if !IsIllegal(i.Err) {
+		t.Errorf("Z80 ed 00: err = %v, want illegal", i.Err)
+	}
+	i, _ = decodeText(t, []uint8{0xed}, TargetZ80)
+	if !IsMalformed(i.Err) {
+		t.Errorf("Z80 lone ed: err = %v, want malformed", i.Err)
+	}
+}
+
+/* cb 30-37 is swap on the SM83 and the undocumented sll on the Z80 */
+func TestDecodeCBSwapByTarget(t *testing.T) {
+	tests := []struct {
+		target CPU
+		data   []uint8
+		want   string
+	}{
+		{TargetSM83, []uint8{0xcb, 0x37}, "swap a"},
+		{TargetSM83, []uint8{0xcb, 0x30}, "swap b"},
+		{TargetSM83, []uint8{0xcb, 0x36}, "swap [hl]"},
+		{TargetZ80, []uint8{0xcb, 0x37}, "sll a"},
+		{TargetZ80, []uint8{0xcb, 0x30}, "sll b"},
+		{TargetZ80, []uint8{0xcb, 0x36}, "sll [hl]"},
+	}
+	for _, tt := range tests {
+		i, got := decodeText(t, tt.data, tt.target)
+		if i.Err != nil || got != tt.want || i.Len() != 2 {
+			t.Errorf("target %d, % x: %q %v over %d bytes, want %q", tt.target, tt.data, got, i.Err, i.Len(), tt.want)
+		}
+	}
+}
+
+/* 0x08 stores sp on the SM83 and swaps af with its shadow on the Z80 */
+func TestDecode0x08ByTarget(t *testing.T) {
+	tests := []struct {
+		target  CPU
+		data    []uint8
+		want    string
+		wantLen int
+	}{
+		{TargetSM83, []uint8{0x08, 0x34, 0x12}, "ld [0x1234], sp", 3},
+		{TargetZ80, []uint8{0x08, 0x34, 0x12}, "ex af, af'", 1},
+	}
+	for _, tt := range tests {
+		i, got := decodeText(t, tt.data, tt.target)
+		if i.Err != nil || got != tt.want || i.Len() != tt.wantLen {
+			t.Errorf("target %d: %q %v over %d bytes, want %q over %d", tt.target, got, i.Err, i.Len(), tt.want, tt.wantLen)
+		}
+	}
+	/* The SM83 form needs its address; the Z80 one has none */
+	if i, _ := decodeText(t, []uint8{0x08}, TargetSM83); !IsMalformed(i.Err) {
+		t.Errorf("SM83 lone 08: err = %v, want malformed", i.Err)
+	}
+	if i, got := decodeText(t, []uint8{0x08}, TargetZ80); i.Err != nil || got != "ex af, af'" {
+		t.Errorf("Z80 lone 08: %q %v", got, i.Err)
+	}
+}
+
+/* 0xd9 returns from an interrupt on the SM83 and swaps register banks on the Z80 */
+func TestDecode0xD9ByTarget(t *testing.T) {
+	tests := []struct {
+		target       CPU
+		want         string
+		wantFlow     FlowType
+		wantCategory Category
+	}{
+		{TargetSM83, "reti", FlowReturn, CategoryControlFlow},
+		{TargetZ80, "exx", FlowSequential, CategoryLoad},
+	}
+	for _, tt := range tests {
+		i, got := decodeText(t, []uint8{0xd9, 0x00}, tt.target)
+		if i.Err != nil || got != tt.want || i.Len() != 1 {
+			t.Errorf("target %d: %q %v over %d bytes, want %q", tt.target, got, i.Err, i.Len(), tt.want)
+		}
+		if i.Flow != tt.wantFlow || i.Category != tt.wantCategory {
+			t.Errorf("target %d: %v %v, want %v %v", tt.target, i.Flow, i.Category, tt.wantFlow, tt.wantCategory)
+		}
+	}
+}